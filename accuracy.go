@@ -0,0 +1,29 @@
+package main
+
+import "github.com/DIMO-Network/model-garage/pkg/vss"
+
+// fieldLocationAccuracy is the name of the signals emitted under
+// Config.AccuracyUERE.
+const fieldLocationAccuracy = "currentLocationAccuracy"
+
+// accuracySignals returns, for each location with an HDOP, a signal
+// holding its estimated accuracy radius in meters: the HDOP times
+// uere.
+func accuracySignals(locs []vss.Signal, uere float64) []vss.Signal {
+	var out []vss.Signal
+	for _, loc := range locs {
+		if loc.ValueLocation.HDOP <= 0 {
+			continue
+		}
+		out = append(out, vss.Signal{
+			TokenID:      loc.TokenID,
+			Timestamp:    loc.Timestamp,
+			Name:         fieldLocationAccuracy,
+			ValueNumber:  loc.ValueLocation.HDOP * uere,
+			Source:       loc.Source,
+			Producer:     loc.Producer,
+			CloudEventID: loc.CloudEventID,
+		})
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccuracyFromHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	actual, err := ProcessSignals(input, WithAccuracyFromHDOP(5))
+
+	// The second location has no HDOP, so no accuracy either.
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 2}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}},
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldLocationAccuracy, ValueNumber: 10},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
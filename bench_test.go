@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// benchSignals deterministically generates n signals resembling a
+// real batch: mostly lat/lon/HDOP triples with a little skew, plus
+// some odometer and speed readings and the occasional exact
+// duplicate.
+func benchSignals(n int) []vss.Signal {
+	r := rand.New(rand.NewPCG(539, 1))
+	start := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	out := make([]vss.Signal, 0, n)
+	t := start
+	lat, lon := 42.3314, -83.0458
+
+	add := func(sig vss.Signal) {
+		if len(out) < n {
+			out = append(out, sig)
+		}
+	}
+
+	for len(out) < n {
+		t = t.Add(time.Second + time.Duration(r.IntN(500))*time.Millisecond)
+		lat += (r.Float64() - 0.5) / 1000
+		lon += (r.Float64() - 0.5) / 1000
+
+		switch k := r.IntN(10); {
+		case k < 6:
+			add(vss.Signal{TokenID: 7, Timestamp: t, Name: vss.FieldCurrentLocationLatitude, ValueNumber: lat})
+			add(vss.Signal{TokenID: 7, Timestamp: t.Add(time.Duration(r.IntN(20)) * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: lon})
+			add(vss.Signal{TokenID: 7, Timestamp: t.Add(time.Duration(r.IntN(20)) * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1 + 4*r.Float64()})
+		case k < 7 && len(out) > 0:
+			add(out[r.IntN(len(out))])
+		case k < 9:
+			add(vss.Signal{TokenID: 7, Timestamp: t, Name: vss.FieldSpeed, ValueNumber: 100 * r.Float64()})
+		default:
+			add(vss.Signal{TokenID: 7, Timestamp: t, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10000 + float64(len(out))})
+		}
+	}
+
+	return out
+}
+
+func benchmarkProcessSignals(b *testing.B, n int, opts ...Option) {
+	input := benchSignals(n)
+	work := make([]vss.Signal, len(input))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		// ProcessSignalsUnsafe sorts and overwrites its input, so
+		// every iteration needs a fresh copy.
+		copy(work, input)
+		_, _ = ProcessSignalsUnsafe(work, opts...)
+	}
+}
+
+func BenchmarkProcessSignals100(b *testing.B)  { benchmarkProcessSignals(b, 100) }
+func BenchmarkProcessSignals10k(b *testing.B)  { benchmarkProcessSignals(b, 10_000) }
+func BenchmarkProcessSignals100k(b *testing.B) { benchmarkProcessSignals(b, 100_000) }
+
+func BenchmarkProcessSignalsWithoutHDOP10k(b *testing.B) {
+	benchmarkProcessSignals(b, 10_000, WithoutHDOP())
+}
+
+func BenchmarkProcessSignalsNoLocation10k(b *testing.B) {
+	input := slices.DeleteFunc(benchSignals(10_000), func(sig vss.Signal) bool {
+		return sig.Name == vss.FieldCurrentLocationLatitude || sig.Name == vss.FieldCurrentLocationLongitude
+	})
+	work := make([]vss.Signal, len(input))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		copy(work, input)
+		_, _ = ProcessSignalsUnsafe(work)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "github.com/DIMO-Network/model-garage/pkg/vss"
+
+// fieldLocationBounds is the name of the summary signals emitted
+// under Config.BoundingBoxSignal.
+const fieldLocationBounds = "currentLocationBoundingBox"
+
+// boundsSignals returns, for each TokenID with at least one location,
+// a signal whose ValueString is the bounding box of its locations as a
+// WKT polygon. The signal is at the time of the last location, and
+// takes its other fields from it. The locations are assumed to be in
+// time order. Boxes are computed naively, so a track that crosses the
+// antimeridian gets one that spans the globe.
+func boundsSignals(locs []vss.Signal) []vss.Signal {
+	var tokens []uint32
+	boxes := make(map[uint32]*BoundingBox)
+	last := make(map[uint32]vss.Signal)
+
+	for _, loc := range locs {
+		lat, lon := loc.ValueLocation.Latitude, loc.ValueLocation.Longitude
+		b, ok := boxes[loc.TokenID]
+		if !ok {
+			b = &BoundingBox{MinLat: lat, MinLon: lon, MaxLat: lat, MaxLon: lon}
+			boxes[loc.TokenID] = b
+			tokens = append(tokens, loc.TokenID)
+		}
+		b.MinLat, b.MaxLat = min(b.MinLat, lat), max(b.MaxLat, lat)
+		b.MinLon, b.MaxLon = min(b.MinLon, lon), max(b.MaxLon, lon)
+		last[loc.TokenID] = loc
+	}
+
+	out := make([]vss.Signal, 0, len(tokens))
+	for _, token := range tokens {
+		l := last[token]
+		out = append(out, vss.Signal{
+			TokenID:      token,
+			Timestamp:    l.Timestamp,
+			Name:         fieldLocationBounds,
+			ValueString:  boxes[token].WKT(),
+			Source:       l.Source,
+			Producer:     l.Producer,
+			CloudEventID: l.CloudEventID,
+		})
+	}
+	return out
+}
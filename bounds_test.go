@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundingBoxSignal(t *testing.T) {
+	now := time.Now()
+
+	fixes := []struct{ lat, lon float64 }{
+		{42.331, -83.046},
+		{42.335, -83.050},
+		{42.329, -83.041},
+		{42.333, -83.044},
+	}
+
+	var input []vss.Signal
+	for i, f := range fixes {
+		ts := now.Add(time.Duration(i) * time.Second)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: f.lat},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: f.lon},
+		)
+	}
+
+	actual, err := ProcessSignalsVerbose(input, WithBoundingBoxSignal())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(3 * time.Second), Name: fieldLocationBounds, ValueString: "POLYGON((-83.05 42.329, -83.041 42.329, -83.041 42.335, -83.05 42.335, -83.05 42.329))"},
+	}, actual.Derived)
+}
+
+func TestBoundsSignalsPerToken(t *testing.T) {
+	now := time.Now()
+
+	locs := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.331, Longitude: -83.046}},
+		{TokenID: 4, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 40.7128, Longitude: -74.006}},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335, Longitude: -83.05}},
+	}
+
+	assert.Equal(t, []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: fieldLocationBounds, ValueString: "POLYGON((-83.05 42.331, -83.046 42.331, -83.046 42.335, -83.05 42.335, -83.05 42.331))"},
+		{TokenID: 4, Timestamp: now, Name: fieldLocationBounds, ValueString: "POLYGON((-74.006 40.7128, -74.006 40.7128, -74.006 40.7128, -74.006 40.7128, -74.006 40.7128))"},
+	}, boundsSignals(locs))
+
+	assert.Empty(t, boundsSignals(nil))
+}
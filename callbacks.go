@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// CallbackPanicError is returned when a function supplied through
+// Config panics. The panic is recovered, and processing carries on as
+// if the function had returned nothing useful. The exceptions are
+// Config.OrderBy and Config.SortLess, which are called while sorting,
+// and whose panics are not recovered.
+type CallbackPanicError struct {
+	// Callback is the name of the Config field holding the function.
+	Callback string
+	// Value is the value passed to panic.
+	Value any
+}
+
+func (e *CallbackPanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Callback, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *CallbackPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverCallback, when deferred, turns a panic in the named
+// user-supplied function into a CallbackPanicError. One bad callback
+// shouldn't take down a whole ingestion worker.
+func (c *Store) recoverCallback(name string) {
+	if r := recover(); r != nil {
+		c.addErr(&CallbackPanicError{Callback: name, Value: r})
+	}
+}
+
+// onDrop calls cfg.OnDrop.
+func (c *Store) onDrop(sig vss.Signal, reason string) {
+	defer c.recoverCallback("OnDrop")
+	c.cfg.OnDrop(sig, reason)
+}
+
+// onCreate calls cfg.OnCreate.
+func (c *Store) onCreate(loc vss.Signal) {
+	defer c.recoverCallback("OnCreate")
+	c.cfg.OnCreate(loc)
+}
+
+// distance calls cfg.Distance. If that panics, the distance is taken
+// to be zero.
+func (c *Store) distance(lat1, lon1, lat2, lon2 float64) (d float64) {
+	defer c.recoverCallback("Distance")
+	return c.cfg.Distance(lat1, lon1, lat2, lon2)
+}
+
+// reproject converts loc, whose fields hold raw coordinate values, to
+// latitude and longitude using cfg.Reproject, if it is set. If that
+// panics, the coordinates come back as NaN, which fails the range
+// check, so that the pair is dropped as unusable.
+func (c *Store) reproject(loc vss.Location) (out vss.Location) {
+	if c.cfg.Reproject == nil {
+		return loc
+	}
+	out = loc
+	out.Latitude, out.Longitude = math.NaN(), math.NaN()
+	defer c.recoverCallback("Reproject")
+	out.Latitude, out.Longitude = c.cfg.Reproject(loc.Longitude, loc.Latitude)
+	return out
+}
+
+// sameKey reports whether a and b have the same cfg.DuplicateKey. If
+// that panics, they are taken to be distinct.
+func (c *Store) sameKey(a, b vss.Signal) (same bool) {
+	defer c.recoverCallback("DuplicateKey")
+	return c.cfg.DuplicateKey(a) == c.cfg.DuplicateKey(b)
+}
+
+// hasValue calls cfg.HasValue. If that panics, the signal is taken to
+// have a value, as it would be without the check.
+func (c *Store) hasValue(sig vss.Signal) (ok bool) {
+	ok = true
+	defer c.recoverCallback("HasValue")
+	return c.cfg.HasValue(sig)
+}
+
+// tracef formats an event and passes it to cfg.Trace. Callers check
+// that cfg.Trace is set first, to avoid formatting for nothing.
+func (c *Store) tracef(format string, args ...any) {
+	defer c.recoverCallback("Trace")
+	c.cfg.Trace(fmt.Sprintf(format, args...))
+}
+
+// traceTime formats t for tracef. Unlike fmtTime, this keeps the
+// fractional seconds, which is where pairing decisions are made.
+func traceTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
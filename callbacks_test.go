@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallbackPanicRecovered(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	boom := errors.New("boom")
+	var seen int
+
+	actual, err := ProcessSignals(input, WithOnCreate(func(loc vss.Signal) {
+		seen++
+		if loc.Timestamp.Equal(now) {
+			panic(boom)
+		}
+	}))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}},
+	)
+
+	var panicErr *CallbackPanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, "OnCreate", panicErr.Callback)
+	}
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 2, seen)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestSetterPanicKeepsLocation(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input, WithSampleCount(func(*vss.Signal, int) { panic("no") }))
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	var panicErr *CallbackPanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestReprojectPanicDropsPair(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 4_688_000},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 331_000},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	actual, stats, err := ProcessSignalsWithStats(input, WithReproject(func(x, y float64) (float64, float64) {
+		panic("bad zone")
+	}))
+
+	var panicErr *CallbackPanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, "Reproject", panicErr.Callback)
+	}
+	assert.Equal(t, 2, stats.Dropped[ReasonOutOfRange])
+	assert.Equal(t, input[2:], actual)
+}
+
+func TestTrace(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+	}
+
+	var events []string
+	_, err := ProcessSignals(input, WithClock(func() time.Time { return now }), WithTrace(func(event string) {
+		events = append(events, event)
+	}))
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"started pair at 2025-08-01T12:00:00Z with currentLocationLatitude",
+		"closing pair started at 2025-08-01T12:00:00Z: gap to currentLocationLatitude at 2025-08-01T12:00:01Z",
+		"creating location at 2025-08-01T12:00:00Z from pair started at 2025-08-01T12:00:00Z",
+		"started pair at 2025-08-01T12:00:01Z with currentLocationLatitude",
+		"closing pair started at 2025-08-01T12:00:01Z: gap to currentLocationLatitude at 2025-08-01T12:00:02Z",
+		"dropped unpaired latitude at 2025-08-01T12:00:01Z",
+		"started pair at 2025-08-01T12:00:02Z with currentLocationLatitude",
+		"dropped pair started at 2025-08-01T12:00:02Z: origin",
+	}, events)
+}
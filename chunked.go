@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// ProcessSignalsChunked is like ProcessSignals, but works through the
+// input roughly chunkSize signals at a time, so that the working
+// memory beyond the input and output stays bounded. A non-positive
+// chunkSize means a single chunk.
+//
+// The signals at the end of a chunk that may still combine with the
+// next, as in Processor.PushBatch, are carried over to it, so a
+// latitude at the end of one chunk still pairs with a longitude at the
+// start of the next. These are the signals within the HDOP window of
+// the end of the chunk and the pair still under construction, so the
+// chunks stay close to chunkSize however dense the input.
+//
+// The passes that look at the created locations as a whole, like
+// Config.MinDistance and Config.DistanceField, run separately on each
+// chunk.
+//
+// The input slice may be reordered.
+func ProcessSignalsChunked(signals []vss.Signal, chunkSize int, opts ...Option) ([]vss.Signal, error) {
+	if signals == nil {
+		return nil, ErrNilInput
+	}
+
+	store := newStore(nil, newConfig(opts))
+	store.sort(signals)
+
+	if chunkSize <= 0 {
+		chunkSize = len(signals)
+	}
+
+	var out, work, held []vss.Signal
+	var errs []error
+
+	for start := 0; start < len(signals); {
+		end := min(start+chunkSize, len(signals))
+
+		// The store writes created signals past the end of its input,
+		// so it can't be handed a subslice of signals.
+		work = append(work[:0], held...)
+		work = append(work, signals[start:end]...)
+		store.next(work)
+		store.hold = end < len(signals)
+		chunkOut, err := store.processSignals()
+		held = append(held[:0], store.held...)
+
+		out = append(out, chunkOut...)
+		if err != nil {
+			errs = append(errs, err)
+			if store.cfg.FailFast {
+				break
+			}
+		}
+
+		start = end
+	}
+
+	return out, errors.Join(errs...)
+}
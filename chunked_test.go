@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/elffjs/locgen/locgentest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessSignalsChunkedStraddle(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldSpeed, ValueNumber: 56},
+		{TokenID: 3, Timestamp: now.Add(time.Minute + 100*time.Millisecond), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(time.Minute + 200*time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	// With a chunk size of 3, the naive cut would fall between the
+	// latitude and the longitude.
+	actual, err := ProcessSignalsChunked(input, 3)
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute + 100*time.Millisecond), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestProcessSignalsChunkedMatchesUnchunked(t *testing.T) {
+	input := benchSignals(1000)
+
+	expected, expectedErr := ProcessSignals(input, WithClock(func() time.Time { return time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC) }))
+	actual, actualErr := ProcessSignalsChunked(input, 50, WithClock(func() time.Time { return time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC) }))
+
+	assert.Equal(t, expectedErr == nil, actualErr == nil)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestProcessSignalsChunkedDense(t *testing.T) {
+	// At 10 Hz there is never a gap as wide as the location window to
+	// cut at.
+	input := locgentest.GenerateTrack(locgentest.TrackOptions{
+		Seed:     11,
+		Fixes:    700,
+		Interval: 100 * time.Millisecond,
+		Skew:     50 * time.Millisecond,
+	})
+	now := time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	expected, expectedErr := ProcessSignals(input, WithClock(func() time.Time { return now }))
+
+	// The clock is read once per chunk.
+	chunks := 0
+	clock := func() time.Time {
+		chunks++
+		return now
+	}
+	actual, actualErr := ProcessSignalsChunked(slices.Clone(input), 100, WithClock(clock))
+
+	assert.NoError(t, expectedErr)
+	assert.NoError(t, actualErr)
+	assert.ElementsMatch(t, expected, actual)
+	assert.Equal(t, len(input)/100, chunks)
+}
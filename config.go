@@ -0,0 +1,1061 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// Config controls how ProcessSignals assembles and filters locations.
+// Use DefaultConfig to get a Config with the standard behavior, and
+// the With* options to adjust it.
+type Config struct {
+	// CoordinatesField is the name given to the location signals that
+	// we create. It defaults to currentLocationCoordinates.
+	CoordinatesField string
+
+	// DefaultSource and DefaultProducer, if non-empty, are stamped on
+	// every created location in place of the Source and Producer
+	// copied from the input. This is for pipelines that track the
+	// origin of a batch as a whole.
+	DefaultSource   string
+	DefaultProducer string
+
+	// HDOPFields holds the signal names that are treated as HDOP
+	// readings. Different device generations report HDOP under
+	// different names. Each location takes the HDOP nearest to it
+	// in time, regardless of which of these names it has, unless
+	// HDOPPriority is set.
+	HDOPFields []string
+	// HDOPPriority, if true, treats HDOPFields as being in order of
+	// preference. Each location takes the nearest HDOP with the first
+	// name that has one in the window, so that, for example, an
+	// aftermarket HDOP wins over an OBD-derived accuracy.
+	HDOPPriority bool
+	// IgnoreHDOP, if true, turns off HDOP handling altogether, for
+	// deployments that never send it. Locations are created without
+	// an HDOP, RequireHDOP and MaxHDOP have no effect, and HDOP
+	// signals pass through like any other.
+	IgnoreHDOP bool
+	// StrictHDOPConfig, if true, makes Validate, and so New and
+	// NewProcessor, reject a Config with no HDOP field names unless
+	// IgnoreHDOP is set. Otherwise such a Config quietly never attaches
+	// an HDOP.
+	StrictHDOPConfig bool
+
+	// InterpolateHDOP, if true, gives each location an HDOP linearly
+	// interpolated in time between the HDOP signals on either side of
+	// it, rather than that of the nearest one. If there is an HDOP in
+	// the window on only one side then that one is used as is.
+	InterpolateHDOP bool
+
+	// HDOPTieBreak decides which HDOP a location gets when the nearest
+	// ones on either side are equally far away. The default is the
+	// earlier one.
+	HDOPTieBreak HDOPTieBreak
+
+	// ConsumeHDOP, if true, drops each HDOP signal that is attached to
+	// a created location, since its value is carried by the location.
+	// An HDOP may still be attached to more than one location in the
+	// same batch. Consumption is per batch, though: under Processor and
+	// ProcessSignalsChunked, an HDOP consumed in one batch is not
+	// carried over, so a location at the start of the next may get a
+	// different HDOP, or none.
+	ConsumeHDOP bool
+
+	// MaxHDOP, if positive, is the largest HDOP that a location may
+	// have. Locations with a higher HDOP are not created, and a
+	// PoorFixError is returned instead. Locations without any HDOP are
+	// let through, since we can't judge them.
+	MaxHDOP float64
+	// RequireHDOP, if true, only lets through locations that have an
+	// HDOP, since the others can't be judged. Latitude-longitude pairs
+	// without one are dropped, and an HDOPMissingError is returned.
+	RequireHDOP bool
+
+	// LocationTimestamp decides which timestamp a created location
+	// gets. The default is the earlier of its latitude and longitude.
+	LocationTimestamp LocationTimestamp
+	// MonotonicLocationTimestamps, if true, makes the timestamps of
+	// the locations created for each TokenID strictly increasing, for
+	// stores that reject a repeated time in a series. A location whose
+	// timestamp is not after that of the previous one for its TokenID
+	// is moved to a nanosecond after it. This shifts the timestamp by
+	// a nanosecond per collision, far below any real clock's
+	// resolution, and keeps the locations in their order.
+	MonotonicLocationTimestamps bool
+
+	// Reproject, if non-nil, converts raw coordinates to latitude and
+	// longitude before they are checked and combined, for trackers
+	// that report positions in some other system, like UTM. It is
+	// given the value of the longitude signal as x and that of the
+	// latitude signal as y. The input signals themselves are not
+	// changed. If it panics, the pair is dropped as out of range.
+	Reproject func(x, y float64) (lat, lon float64)
+
+	// WrapLongitude, if true, maps longitudes into [-180, 180) before
+	// they are checked and paired, for decoders that report them in
+	// [0, 360). The longitude signals themselves are rewritten too.
+	// There is no such treatment for latitude.
+	WrapLongitude bool
+
+	// Antimeridian decides how a created location exactly on the
+	// antimeridian is written, since +180 and -180 are the same line
+	// but some bounding-box queries treat them differently. The default
+	// leaves the longitude as it came. WrapLongitude already maps a
+	// longitude signal of +180 to -180, so with it the policy only
+	// makes a difference when rounding under CoordinatePrecision, or
+	// Reproject, lands on +180.
+	Antimeridian Antimeridian
+
+	// CoordinatePrecision, if positive, is the number of decimal
+	// places to which the latitude and longitude of created locations
+	// are rounded, half to even. Five places is about a meter, which
+	// is already more than most GPS fixes can claim. The rounding comes
+	// before the origin, range, and BoundingBox checks.
+	CoordinatePrecision int
+
+	// BoundingBox, if non-nil, is the region in which locations may be
+	// created. A location outside it is not created, and an
+	// OutOfRegionError is returned instead. The latitude and longitude
+	// signals themselves are kept.
+	BoundingBox *BoundingBox
+
+	// MaxFuture is how far past the current time a signal's timestamp
+	// may be before we drop it. A signal exactly MaxFuture ahead is
+	// kept; one a nanosecond later is dropped.
+	MaxFuture time.Duration
+	// Now returns the current time. It is called once per batch. This
+	// exists so that tests can pin the clock.
+	Now func() time.Time
+
+	// CheckTimeOrder, if true, returns a BackwardsTimeWarning for each
+	// signal that, in the order given, is earlier than a previous
+	// signal for the same TokenID. Processing is unaffected, since
+	// signals are sorted anyway, but some devices have clocks worth
+	// knowing about.
+	CheckTimeOrder bool
+
+	// LastKnownWindow, if positive, lets a latitude without a partner
+	// be paired with the most recent usable longitude for the same
+	// TokenID, as long as that is less than this far in the past, and
+	// likewise for a longitude. This rescues fixes from trackers that
+	// alternate coordinates further apart than the usual window. The
+	// result must still pass the origin and range checks. Such
+	// locations are passed to MarkLastKnown, if it is non-nil, so that
+	// they can be flagged.
+	LastKnownWindow time.Duration
+	MarkLastKnown   func(sig *vss.Signal)
+
+	// CorrelationKey, if non-nil, returns a trip or sample ID for a
+	// signal, or an empty string if it has none. A latitude and a
+	// longitude that are alone in sharing a key are combined however
+	// far apart they are, along with an HDOP with the same key if
+	// there is exactly one. Other signals are paired by time as usual.
+	// Keys only pair within a batch: under Processor and
+	// ProcessSignalsChunked, halves that share a key but arrive in
+	// different batches are paired by time instead.
+	CorrelationKey func(sig vss.Signal) string
+
+	// NearestPairing, if true, pairs each latitude, in time order, with
+	// the nearest unused longitude for the same TokenID within the
+	// window, instead of starting a new pair whenever a coordinate
+	// repeats. This keeps both fixes when a tracker sends lat, lat,
+	// lon, lon. Coordinates left without a partner go through the
+	// usual pairing.
+	NearestPairing bool
+
+	// SourceConsistency, if true, refuses to combine a latitude and
+	// longitude whose Source fields differ, as happens when a vehicle
+	// has two GPS modules. Both are dropped, and a SourceMismatchError
+	// is returned.
+	SourceConsistency bool
+
+	// LocationGap is the span of time within which signals may be
+	// combined into one location. Signals exactly this far apart may
+	// not. It defaults to 500ms.
+	LocationGap time.Duration
+	// SimultaneityWindow, if positive, narrows the span within which a
+	// latitude and longitude count as the same fix, while HDOP may
+	// still be taken from anywhere within LocationGap or HDOPGap. At
+	// high sample rates this keeps the latitude of one fix from pairing
+	// with the longitude of the next.
+	SimultaneityWindow time.Duration
+	// HDOPGap, if positive, is the span within which an HDOP may be
+	// attached to a location, in place of LocationGap. Some devices
+	// update HDOP much less often than position, and a reading stays
+	// good for a few seconds. ProcessSignalsChunked and Processor carry
+	// this much of the end of each batch over to the next. This is
+	// ignored under OrderBy.
+	HDOPGap time.Duration
+	// ReorderWindow, if positive, makes Processor hold back at least
+	// this much of the end of each batch, so that signals arriving up
+	// to this late in a later batch are still sorted into place before
+	// pairing. Anything later than that is paired as it comes. This is
+	// ignored under OrderBy.
+	ReorderWindow time.Duration
+
+	// DedupScope decides which signals are checked for duplicates and
+	// conflicts. The default is all of them.
+	DedupScope DedupScope
+	// ConflictResolution decides what happens when latitudes or
+	// longitudes for a TokenID share a timestamp but not a value. The
+	// default keeps them all, with a ConflictWarning. Other signals
+	// are always kept, and nothing is resolved under DedupNone.
+	ConflictResolution ConflictResolution
+	// DuplicateKey, if non-nil, decides which signals are duplicates:
+	// those with the same timestamp and name whose keys are equal. Of
+	// each set of duplicates, the first in the input is kept. If it is
+	// nil then only exact copies are duplicates.
+	DuplicateKey func(sig vss.Signal) string
+
+	// FrozenClockThreshold, if positive, is the number of latitude and
+	// longitude signals for one TokenID at a single timestamp that
+	// triggers a FrozenClockWarning. A failing GPS module may stamp
+	// many fixes with the same time, and these would otherwise be
+	// paired arbitrarily. If DropFrozen is also set then only one
+	// latitude and one longitude from each such group are kept; which
+	// ones is unspecified. This is skipped under OrderBy.
+	FrozenClockThreshold int
+	DropFrozen           bool
+
+	// OrderBy, if non-nil, supplies the key used to order signals and
+	// to decide which ones are close enough to pair, in place of the
+	// timestamp. This is for devices that report a sequence number
+	// and stamp every signal with the same time. Signals whose keys
+	// differ by less than SeqGap may be paired. LocationGap and
+	// SimultaneityWindow are then ignored. Unlike the other callbacks,
+	// it is not protected from panics: it runs inside the sort, where
+	// there is no sensible way to carry on.
+	OrderBy func(sig vss.Signal) int64
+	SeqGap  int64
+
+	// SortLess, if non-nil, replaces the comparison used to put
+	// signals in processing order, for callers who want, say, each
+	// TokenID kept together. Within a TokenID it must order signals
+	// as the default does, by timestamp (or OrderBy) and then name,
+	// since pairing and HDOP lookup only look at neighbors. Signals
+	// with different TokenIDs are then never combined. The streaming
+	// functions, ProcessSignalsChunked, Processor, and MergeBoundary,
+	// cut batches by time and need the default order. Like OrderBy, it
+	// is not protected from panics.
+	SortLess func(a, b vss.Signal) int
+
+	// StrictFields, if true, returns an ErrSuspectFieldName error for
+	// each distinct signal name that is a near miss for one of the
+	// location fields, like currentLocationLatitud. This guards against
+	// upstream renames that would otherwise silently stop location
+	// creation.
+	StrictFields bool
+
+	// FailFast, if true, stops processing at the first error. The
+	// returned slice then holds only the signals that were settled
+	// before the error, along with any locations created from them.
+	// The rest are dropped with ReasonFailFast. Locations paired in
+	// advance, such as by CorrelationKey, count as created when the
+	// pass reaches their earlier signal. Warnings from the checks
+	// before pairing, such as ConflictWarning, are kept only if they
+	// come before the cut in time.
+	FailFast bool
+
+	// MaxErrors, if positive, is the most individual errors that are
+	// kept for a batch. Later ones are only counted, and a single
+	// SuppressedError reports how many there were. This bounds the
+	// memory that a pathological batch can take.
+	MaxErrors int
+
+	// AggregateErrors, if true, replaces the individual errors of each
+	// category with a single AggregateError, so that a malfunctioning
+	// device doesn't produce thousands of lines of errors. The
+	// individual errors are still available through errors.As.
+	AggregateErrors bool
+
+	// DropRepeatedFixes, if true, suppresses any created location whose
+	// latitude and longitude exactly match those of the previous
+	// location created for the same TokenID. Parked vehicles tend to
+	// report the same position over and over.
+	DropRepeatedFixes bool
+	// RepeatedFixesCompareHDOP additionally requires HDOP to match for
+	// a location to count as a repeat. It has no effect unless
+	// DropRepeatedFixes is set.
+	RepeatedFixesCompareHDOP bool
+
+	// MaxLocationsPerToken, if positive, is the most locations that a
+	// batch may create for one TokenID. Past that, no more are created
+	// for it, and a single CapExceededError is returned. The latitude
+	// and longitude signals are kept either way. This protects
+	// downstream storage from devices that report many fixes a second.
+	MaxLocationsPerToken int
+
+	// MinDistance, if positive, thins the created locations for each
+	// TokenID so that consecutive locations are at least this many
+	// meters apart. The first and last location for each TokenID are
+	// always kept. Latitude and longitude signals are not affected.
+	// Note that OnCreate is called before thinning.
+	MinDistance float64
+
+	// DistanceField, if non-empty, turns on the emission of a running
+	// travelled distance for each TokenID, as a signal with this name
+	// at the timestamp of each created location. The distance is in
+	// kilometers, measured along great circles between consecutive
+	// locations, and starts from DistanceOffset. This is useful when a
+	// vehicle doesn't report an odometer.
+	DistanceField  string
+	DistanceOffset float64
+
+	// AccuracyUERE, if positive, turns on the emission of an accuracy
+	// radius in meters for each created location with an HDOP, as a
+	// signal named currentLocationAccuracy at the same timestamp. The
+	// radius is the HDOP times this user range error, which depends on
+	// the receiver; 5 meters is typical for consumer GPS. vss.Location
+	// has no place for it.
+	AccuracyUERE float64
+
+	// FixTTL, if positive, turns on the emission of an expiry for each
+	// created location, as a signal named currentLocationExpiry at the
+	// same timestamp. Its ValueString is the time at which the fix
+	// should stop being treated as current, the location's Timestamp
+	// plus FixTTL, in RFC 3339 format. This is a hint for caches
+	// downstream; nothing here reads it.
+	FixTTL time.Duration
+
+	// SatellitesField, if non-empty, names the signal holding the
+	// number of satellites in view, and turns on the emission of a
+	// companion signal named currentLocationSatellites for each created
+	// location with one of these nearby, within LocationGap or
+	// HDOPGap. Like HDOP, it never stops a location from being
+	// created. Satellite counts that are negative or not whole numbers
+	// are dropped with an InvalidSatellitesError. vss.Location has no
+	// place for the count.
+	SatellitesField string
+
+	// SmoothingWindow, if positive, turns on the emission of a
+	// smoothed copy of each created location, named
+	// currentLocationCoordinatesSmoothed, alongside the raw one. Its
+	// coordinates are the moving average over the last SmoothingWindow
+	// locations for the TokenID, including itself. The average is
+	// taken naively, so a track that crosses the antimeridian smooths
+	// badly.
+	SmoothingWindow int
+
+	// BoundingBoxSignal, if true, turns on the emission of one summary
+	// signal per TokenID with any created locations, named
+	// currentLocationBoundingBox, whose ValueString is the bounding box
+	// of those locations as a WKT polygon. It is at the time of the
+	// last location. This saves trip previews a scan of every point.
+	BoundingBoxSignal bool
+
+	// GapMarkerThreshold, if positive, turns on the emission of a
+	// marker signal named currentLocationGap wherever two consecutive
+	// created locations for a TokenID are more than this far apart,
+	// which usually means that the vehicle went dark. The marker is at
+	// the time of the earlier location, and its value is the length of
+	// the gap in seconds. This helps downstream trip splitting.
+	GapMarkerThreshold time.Duration
+
+	// Distance measures the distance between locations for
+	// MinDistance and DistanceField. It defaults to Haversine.
+	Distance DistanceFunc
+
+	// UnitSanityCheck, if true, inspects the created locations as a
+	// whole and returns ErrSuspectUnits if they look like they were
+	// given in radians rather than degrees. See checkUnits for the
+	// heuristic.
+	UnitSanityCheck bool
+
+	// Confidence and SetConfidence, if both non-nil, are used to give
+	// each created location the minimum confidence of the latitude,
+	// longitude, and HDOP signals it was built from. vss.Signal does
+	// not yet carry a confidence score, so for now the caller has to
+	// say where it lives.
+	Confidence    func(sig vss.Signal) float64
+	SetConfidence func(sig *vss.Signal, conf float64)
+
+	// SetSampleCount, if non-nil, is called on each created location
+	// with the number of input signals it was built from: 2 for a bare
+	// latitude-longitude pair, and 3 if an HDOP was attached. As with
+	// confidence, vss.Signal has no place for this, so the caller
+	// decides where it goes.
+	SetSampleCount func(sig *vss.Signal, n int)
+
+	// HasValue, if non-nil, reports whether a latitude or longitude
+	// signal actually carries a value. Those that don't are dropped
+	// with a MissingValueError rather than paired as a zero
+	// coordinate. vss.Signal has no such indicator yet, so the caller
+	// has to know where to look. Config.FailFast does not stop at
+	// these, which are checked before pairing.
+	HasValue func(sig vss.Signal) bool
+
+	// SetValueType, if non-nil, is called on each created location to
+	// mark it as holding a location value rather than a number, for
+	// schemas that tag each row with its value type. vss.Signal has
+	// no such field yet; once it does, created locations will get it
+	// directly. Passed-through signals are left alone.
+	SetValueType func(sig *vss.Signal)
+
+	// LocationsOnly, if true, makes the output consist of just the
+	// created locations. The input signals and any derived signals are
+	// left out, but errors are still returned. Reconcile can't account
+	// for the missing signals in this mode.
+	LocationsOnly bool
+
+	// OnDrop, if non-nil, is called with each signal as it is dropped,
+	// along with one of the Reason constants.
+	OnDrop func(sig vss.Signal, reason string)
+	// OnCreate, if non-nil, is called with each location signal as it
+	// is created.
+	OnCreate func(loc vss.Signal)
+	// Trace, if non-nil, is called with a line of text describing each
+	// decision made while pairing, such as starting a pair, closing it
+	// because of a gap, or dropping an unpaired coordinate. This is
+	// for debugging, and the wording may change.
+	Trace func(event string)
+}
+
+// HDOPTieBreak is a rule for choosing between two HDOP signals that
+// are equally close to a location.
+type HDOPTieBreak int
+
+const (
+	// TieBreakEarlier picks the earlier HDOP.
+	TieBreakEarlier HDOPTieBreak = iota
+	// TieBreakLater picks the later HDOP.
+	TieBreakLater
+	// TieBreakLower picks the lower HDOP, that is, the better fix. If
+	// they are equal, the earlier one is used.
+	TieBreakLower
+)
+
+// LocationTimestamp is a rule for choosing the timestamp of a created
+// location from those of its latitude and longitude.
+type LocationTimestamp int
+
+const (
+	// TimestampEarliest uses the earlier of the two.
+	TimestampEarliest LocationTimestamp = iota
+	// TimestampLatitude uses the latitude's timestamp.
+	TimestampLatitude
+	// TimestampLongitude uses the longitude's timestamp.
+	TimestampLongitude
+	// TimestampLatest uses the later of the two.
+	TimestampLatest
+)
+
+// Antimeridian is a policy for longitudes exactly on the antimeridian.
+type Antimeridian int
+
+const (
+	// AntimeridianAsIs leaves +180 and -180 alone.
+	AntimeridianAsIs Antimeridian = iota
+	// AntimeridianWest writes +180 as -180, matching the [-180, 180)
+	// range of Config.WrapLongitude.
+	AntimeridianWest
+)
+
+// ConflictResolution is a way of settling conflicting coordinates.
+type ConflictResolution int
+
+const (
+	// ConflictKeepAll keeps every value, which leaves the pairing to
+	// sort them out.
+	ConflictKeepAll ConflictResolution = iota
+	// ConflictFirst keeps the first value in the input and drops the
+	// rest.
+	ConflictFirst
+	// ConflictLast keeps the last value in the input and drops the
+	// rest.
+	ConflictLast
+	// ConflictMean keeps the first signal with its value replaced by
+	// the mean of all of them, and drops the rest.
+	ConflictMean
+	// ConflictReject drops all of them, with a ConflictError in place
+	// of the ConflictWarning.
+	ConflictReject
+)
+
+// DedupScope is a choice of signals to deduplicate.
+type DedupScope int
+
+const (
+	// DedupAll deduplicates every signal.
+	DedupAll DedupScope = iota
+	// DedupLocationOnly deduplicates only latitude, longitude, HDOP,
+	// and combined location signals, and passes the rest through
+	// untouched.
+	DedupLocationOnly
+	// DedupNone turns deduplication off.
+	DedupNone
+)
+
+// DefaultConfig returns the configuration used by ProcessSignals when
+// no options are given.
+func DefaultConfig() Config {
+	return Config{
+		CoordinatesField: fieldCoordinates,
+		MaxFuture:        defaultMaxFuture,
+		LocationGap:      maxLatLongDur,
+		Now:              time.Now,
+		HDOPFields:       []string{vss.FieldDIMOAftermarketHDOP},
+		Distance:         Haversine,
+	}
+}
+
+// Validate checks that c makes sense, and returns a ConfigError for
+// each field that doesn't, joined together. New and NewProcessor call
+// this; the ProcessSignals functions do not, and make the best of
+// whatever they are given.
+func (c Config) Validate() error {
+	var errs []error
+	bad := func(field, problem string, args ...any) {
+		errs = append(errs, &ConfigError{Field: field, Problem: fmt.Sprintf(problem, args...)})
+	}
+
+	if c.CoordinatesField == "" {
+		bad("CoordinatesField", "must not be empty")
+	}
+	if c.Now == nil {
+		bad("Now", "must not be nil")
+	}
+	if c.Distance == nil {
+		bad("Distance", "must not be nil")
+	}
+
+	durations := []struct {
+		field string
+		d     time.Duration
+	}{
+		{"LocationGap", c.LocationGap},
+		{"SimultaneityWindow", c.SimultaneityWindow},
+		{"HDOPGap", c.HDOPGap},
+		{"ReorderWindow", c.ReorderWindow},
+		{"LastKnownWindow", c.LastKnownWindow},
+		{"MaxFuture", c.MaxFuture},
+		{"GapMarkerThreshold", c.GapMarkerThreshold},
+		{"FixTTL", c.FixTTL},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			bad(d.field, "must not be negative, got %s", d.d)
+		}
+	}
+
+	counts := []struct {
+		field string
+		n     int64
+	}{
+		{"SeqGap", c.SeqGap},
+		{"MaxErrors", int64(c.MaxErrors)},
+		{"MaxLocationsPerToken", int64(c.MaxLocationsPerToken)},
+		{"FrozenClockThreshold", int64(c.FrozenClockThreshold)},
+		{"SmoothingWindow", int64(c.SmoothingWindow)},
+	}
+	for _, n := range counts {
+		if n.n < 0 {
+			bad(n.field, "must not be negative, got %d", n.n)
+		}
+	}
+
+	thresholds := []struct {
+		field string
+		x     float64
+	}{
+		{"MaxHDOP", c.MaxHDOP},
+		{"MinDistance", c.MinDistance},
+		{"AccuracyUERE", c.AccuracyUERE},
+	}
+	for _, t := range thresholds {
+		// Written this way to catch NaN.
+		if !(0 <= t.x && t.x <= math.MaxFloat64) {
+			bad(t.field, "must be finite and not negative, got %g", t.x)
+		}
+	}
+	if math.IsNaN(c.DistanceOffset) || math.IsInf(c.DistanceOffset, 0) {
+		bad("DistanceOffset", "must be finite, got %g", c.DistanceOffset)
+	}
+
+	if c.StrictHDOPConfig && !c.IgnoreHDOP && !slices.ContainsFunc(c.HDOPFields, func(name string) bool { return name != "" }) {
+		bad("HDOPFields", "must name at least one field under StrictHDOPConfig, or use IgnoreHDOP")
+	}
+
+	if c.CoordinatePrecision < 0 || c.CoordinatePrecision > 15 {
+		bad("CoordinatePrecision", "must be between 0 and 15, got %d", c.CoordinatePrecision)
+	}
+
+	if b := c.BoundingBox; b != nil {
+		if !(-90 <= b.MinLat && b.MinLat <= 90 && -90 <= b.MaxLat && b.MaxLat <= 90) {
+			bad("BoundingBox", "latitudes must be within [-90, 90], got %g and %g", b.MinLat, b.MaxLat)
+		} else if b.MinLat > b.MaxLat {
+			bad("BoundingBox", "MinLat %g is greater than MaxLat %g", b.MinLat, b.MaxLat)
+		}
+		if !(-180 <= b.MinLon && b.MinLon <= 180 && -180 <= b.MaxLon && b.MaxLon <= 180) {
+			bad("BoundingBox", "longitudes must be within [-180, 180], got %g and %g", b.MinLon, b.MaxLon)
+		}
+	}
+
+	if c.HDOPTieBreak < TieBreakEarlier || c.HDOPTieBreak > TieBreakLower {
+		bad("HDOPTieBreak", "unknown value %d", c.HDOPTieBreak)
+	}
+	if c.LocationTimestamp < TimestampEarliest || c.LocationTimestamp > TimestampLatest {
+		bad("LocationTimestamp", "unknown value %d", c.LocationTimestamp)
+	}
+	if c.Antimeridian < AntimeridianAsIs || c.Antimeridian > AntimeridianWest {
+		bad("Antimeridian", "unknown value %d", c.Antimeridian)
+	}
+	if c.DedupScope < DedupAll || c.DedupScope > DedupNone {
+		bad("DedupScope", "unknown value %d", c.DedupScope)
+	}
+	if c.ConflictResolution < ConflictKeepAll || c.ConflictResolution > ConflictReject {
+		bad("ConflictResolution", "unknown value %d", c.ConflictResolution)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Option modifies a Config.
+type Option func(*Config)
+
+// WithCoordinatesField sets the name of the created location signals.
+func WithCoordinatesField(name string) Option {
+	return func(c *Config) {
+		c.CoordinatesField = name
+	}
+}
+
+// WithDefaultSource sets Config.DefaultSource.
+func WithDefaultSource(source string) Option {
+	return func(c *Config) {
+		c.DefaultSource = source
+	}
+}
+
+// WithDefaultProducer sets Config.DefaultProducer.
+func WithDefaultProducer(producer string) Option {
+	return func(c *Config) {
+		c.DefaultProducer = producer
+	}
+}
+
+// WithHDOPFields replaces the set of signal names treated as HDOP.
+func WithHDOPFields(names ...string) Option {
+	return func(c *Config) {
+		c.HDOPFields = names
+	}
+}
+
+// WithoutHDOP sets Config.IgnoreHDOP.
+func WithoutHDOP() Option {
+	return func(c *Config) {
+		c.IgnoreHDOP = true
+	}
+}
+
+// WithStrictHDOPConfig sets Config.StrictHDOPConfig.
+func WithStrictHDOPConfig() Option {
+	return func(c *Config) {
+		c.StrictHDOPConfig = true
+	}
+}
+
+// WithHDOPPriority sets Config.HDOPFields to the given names, in
+// order of preference, and enables Config.HDOPPriority.
+func WithHDOPPriority(names ...string) Option {
+	return func(c *Config) {
+		c.HDOPFields = names
+		c.HDOPPriority = true
+	}
+}
+
+// WithInterpolatedHDOP enables Config.InterpolateHDOP.
+func WithInterpolatedHDOP() Option {
+	return func(c *Config) {
+		c.InterpolateHDOP = true
+	}
+}
+
+// WithHDOPTieBreak sets Config.HDOPTieBreak.
+func WithHDOPTieBreak(rule HDOPTieBreak) Option {
+	return func(c *Config) {
+		c.HDOPTieBreak = rule
+	}
+}
+
+// WithConsumeHDOP enables Config.ConsumeHDOP.
+func WithConsumeHDOP() Option {
+	return func(c *Config) {
+		c.ConsumeHDOP = true
+	}
+}
+
+// WithMaxHDOP sets Config.MaxHDOP.
+func WithMaxHDOP(hdop float64) Option {
+	return func(c *Config) {
+		c.MaxHDOP = hdop
+	}
+}
+
+// WithRequireHDOP enables Config.RequireHDOP.
+func WithRequireHDOP() Option {
+	return func(c *Config) {
+		c.RequireHDOP = true
+	}
+}
+
+// WithLocationTimestamp sets Config.LocationTimestamp.
+func WithLocationTimestamp(rule LocationTimestamp) Option {
+	return func(c *Config) {
+		c.LocationTimestamp = rule
+	}
+}
+
+// WithMonotonicLocationTimestamps enables
+// Config.MonotonicLocationTimestamps.
+func WithMonotonicLocationTimestamps() Option {
+	return func(c *Config) {
+		c.MonotonicLocationTimestamps = true
+	}
+}
+
+// WithReproject sets Config.Reproject.
+func WithReproject(f func(x, y float64) (lat, lon float64)) Option {
+	return func(c *Config) {
+		c.Reproject = f
+	}
+}
+
+// WithWrapLongitude enables Config.WrapLongitude.
+func WithWrapLongitude() Option {
+	return func(c *Config) {
+		c.WrapLongitude = true
+	}
+}
+
+// WithAntimeridian sets Config.Antimeridian.
+func WithAntimeridian(policy Antimeridian) Option {
+	return func(c *Config) {
+		c.Antimeridian = policy
+	}
+}
+
+// WithCoordinatePrecision sets Config.CoordinatePrecision.
+func WithCoordinatePrecision(decimals int) Option {
+	return func(c *Config) {
+		c.CoordinatePrecision = decimals
+	}
+}
+
+// WithBoundingBox sets Config.BoundingBox. If minLon is greater than
+// maxLon then the box crosses the antimeridian.
+func WithBoundingBox(minLat, minLon, maxLat, maxLon float64) Option {
+	return func(c *Config) {
+		c.BoundingBox = &BoundingBox{MinLat: minLat, MinLon: minLon, MaxLat: maxLat, MaxLon: maxLon}
+	}
+}
+
+// WithMaxFuture sets Config.MaxFuture.
+func WithMaxFuture(d time.Duration) Option {
+	return func(c *Config) {
+		c.MaxFuture = d
+	}
+}
+
+// WithClock sets Config.Now.
+func WithClock(now func() time.Time) Option {
+	return func(c *Config) {
+		c.Now = now
+	}
+}
+
+// WithCorrelationKey sets Config.CorrelationKey.
+func WithCorrelationKey(key func(sig vss.Signal) string) Option {
+	return func(c *Config) {
+		c.CorrelationKey = key
+	}
+}
+
+// WithNearestPairing sets Config.NearestPairing.
+func WithNearestPairing() Option {
+	return func(c *Config) {
+		c.NearestPairing = true
+	}
+}
+
+// WithSourceConsistency enables Config.SourceConsistency.
+func WithSourceConsistency() Option {
+	return func(c *Config) {
+		c.SourceConsistency = true
+	}
+}
+
+// WithLocationGap sets Config.LocationGap.
+func WithLocationGap(d time.Duration) Option {
+	return func(c *Config) {
+		c.LocationGap = d
+	}
+}
+
+// WithSimultaneityWindow sets Config.SimultaneityWindow.
+func WithSimultaneityWindow(d time.Duration) Option {
+	return func(c *Config) {
+		c.SimultaneityWindow = d
+	}
+}
+
+// WithCheckTimeOrder enables Config.CheckTimeOrder.
+func WithCheckTimeOrder() Option {
+	return func(c *Config) {
+		c.CheckTimeOrder = true
+	}
+}
+
+// WithLastKnownPairing sets Config.LastKnownWindow and
+// Config.MarkLastKnown.
+func WithLastKnownPairing(window time.Duration, mark func(sig *vss.Signal)) Option {
+	return func(c *Config) {
+		c.LastKnownWindow = window
+		c.MarkLastKnown = mark
+	}
+}
+
+// WithConflictResolution sets Config.ConflictResolution.
+func WithConflictResolution(res ConflictResolution) Option {
+	return func(c *Config) {
+		c.ConflictResolution = res
+	}
+}
+
+// WithDedupScope sets Config.DedupScope.
+func WithDedupScope(scope DedupScope) Option {
+	return func(c *Config) {
+		c.DedupScope = scope
+	}
+}
+
+// WithDuplicateKey sets Config.DuplicateKey.
+func WithDuplicateKey(key func(sig vss.Signal) string) Option {
+	return func(c *Config) {
+		c.DuplicateKey = key
+	}
+}
+
+// WithFrozenClockDetection sets Config.FrozenClockThreshold and
+// Config.DropFrozen.
+func WithFrozenClockDetection(threshold int, drop bool) Option {
+	return func(c *Config) {
+		c.FrozenClockThreshold = threshold
+		c.DropFrozen = drop
+	}
+}
+
+// WithHDOPGap sets Config.HDOPGap.
+func WithHDOPGap(d time.Duration) Option {
+	return func(c *Config) {
+		c.HDOPGap = d
+	}
+}
+
+// WithReorderWindow sets Config.ReorderWindow.
+func WithReorderWindow(d time.Duration) Option {
+	return func(c *Config) {
+		c.ReorderWindow = d
+	}
+}
+
+// WithSortLess sets Config.SortLess.
+func WithSortLess(less func(a, b vss.Signal) int) Option {
+	return func(c *Config) {
+		c.SortLess = less
+	}
+}
+
+// WithOrderBy sets Config.OrderBy and Config.SeqGap.
+func WithOrderBy(key func(sig vss.Signal) int64, gap int64) Option {
+	return func(c *Config) {
+		c.OrderBy = key
+		c.SeqGap = gap
+	}
+}
+
+// WithStrictFields enables Config.StrictFields.
+func WithStrictFields() Option {
+	return func(c *Config) {
+		c.StrictFields = true
+	}
+}
+
+// WithFailFast enables Config.FailFast.
+func WithFailFast() Option {
+	return func(c *Config) {
+		c.FailFast = true
+	}
+}
+
+// WithMaxErrors sets Config.MaxErrors.
+func WithMaxErrors(n int) Option {
+	return func(c *Config) {
+		c.MaxErrors = n
+	}
+}
+
+// WithAggregateErrors enables Config.AggregateErrors.
+func WithAggregateErrors() Option {
+	return func(c *Config) {
+		c.AggregateErrors = true
+	}
+}
+
+// WithDropRepeatedFixes enables Config.DropRepeatedFixes. If
+// compareHDOP is true then HDOP must also match.
+func WithDropRepeatedFixes(compareHDOP bool) Option {
+	return func(c *Config) {
+		c.DropRepeatedFixes = true
+		c.RepeatedFixesCompareHDOP = compareHDOP
+	}
+}
+
+// WithMaxLocationsPerToken sets Config.MaxLocationsPerToken.
+func WithMaxLocationsPerToken(n int) Option {
+	return func(c *Config) {
+		c.MaxLocationsPerToken = n
+	}
+}
+
+// WithMinDistance sets Config.MinDistance.
+func WithMinDistance(meters float64) Option {
+	return func(c *Config) {
+		c.MinDistance = meters
+	}
+}
+
+// WithTravelledDistance sets Config.DistanceField and
+// Config.DistanceOffset.
+func WithTravelledDistance(name string, offset float64) Option {
+	return func(c *Config) {
+		c.DistanceField = name
+		c.DistanceOffset = offset
+	}
+}
+
+// WithAccuracyFromHDOP sets Config.AccuracyUERE.
+func WithAccuracyFromHDOP(uere float64) Option {
+	return func(c *Config) {
+		c.AccuracyUERE = uere
+	}
+}
+
+// WithFixTTL sets Config.FixTTL.
+func WithFixTTL(d time.Duration) Option {
+	return func(c *Config) {
+		c.FixTTL = d
+	}
+}
+
+// WithSatellites sets Config.SatellitesField to dimoAftermarketNSAT.
+func WithSatellites() Option {
+	return func(c *Config) {
+		c.SatellitesField = vss.FieldDIMOAftermarketNSAT
+	}
+}
+
+// WithSmoothing sets Config.SmoothingWindow.
+func WithSmoothing(window int) Option {
+	return func(c *Config) {
+		c.SmoothingWindow = window
+	}
+}
+
+// WithBoundingBoxSignal sets Config.BoundingBoxSignal.
+func WithBoundingBoxSignal() Option {
+	return func(c *Config) {
+		c.BoundingBoxSignal = true
+	}
+}
+
+// WithGapMarkers sets Config.GapMarkerThreshold.
+func WithGapMarkers(threshold time.Duration) Option {
+	return func(c *Config) {
+		c.GapMarkerThreshold = threshold
+	}
+}
+
+// WithDistanceFunc sets Config.Distance.
+func WithDistanceFunc(f DistanceFunc) Option {
+	return func(c *Config) {
+		c.Distance = f
+	}
+}
+
+// WithUnitSanityCheck enables Config.UnitSanityCheck.
+func WithUnitSanityCheck() Option {
+	return func(c *Config) {
+		c.UnitSanityCheck = true
+	}
+}
+
+// WithConfidence sets Config.Confidence and Config.SetConfidence.
+func WithConfidence(get func(sig vss.Signal) float64, set func(sig *vss.Signal, conf float64)) Option {
+	return func(c *Config) {
+		c.Confidence = get
+		c.SetConfidence = set
+	}
+}
+
+// WithSampleCount sets Config.SetSampleCount.
+func WithSampleCount(set func(sig *vss.Signal, n int)) Option {
+	return func(c *Config) {
+		c.SetSampleCount = set
+	}
+}
+
+// WithHasValue sets Config.HasValue.
+func WithHasValue(has func(sig vss.Signal) bool) Option {
+	return func(c *Config) {
+		c.HasValue = has
+	}
+}
+
+// WithValueType sets Config.SetValueType.
+func WithValueType(set func(sig *vss.Signal)) Option {
+	return func(c *Config) {
+		c.SetValueType = set
+	}
+}
+
+// WithLocationsOnly enables Config.LocationsOnly.
+func WithLocationsOnly() Option {
+	return func(c *Config) {
+		c.LocationsOnly = true
+	}
+}
+
+// WithOnDrop sets Config.OnDrop.
+func WithOnDrop(f func(sig vss.Signal, reason string)) Option {
+	return func(c *Config) {
+		c.OnDrop = f
+	}
+}
+
+// WithOnCreate sets Config.OnCreate.
+func WithOnCreate(f func(loc vss.Signal)) Option {
+	return func(c *Config) {
+		c.OnCreate = f
+	}
+}
+
+// WithTrace sets Config.Trace.
+func WithTrace(f func(event string)) Option {
+	return func(c *Config) {
+		c.Trace = f
+	}
+}
+
+func newConfig(opts []Option) Config {
+	cfg := DefaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
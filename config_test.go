@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "negative gap",
+			opts: []Option{WithLocationGap(-time.Second)},
+			want: []string{"invalid Config.LocationGap: must not be negative, got -1s"},
+		},
+		{
+			name: "precision",
+			opts: []Option{WithCoordinatePrecision(16)},
+			want: []string{"invalid Config.CoordinatePrecision: must be between 0 and 15, got 16"},
+		},
+		{
+			name: "inverted bounding box",
+			opts: []Option{WithBoundingBox(43, -84, 42, -83)},
+			want: []string{"invalid Config.BoundingBox: MinLat 43 is greater than MaxLat 42"},
+		},
+		{
+			name: "antimeridian bounding box",
+			opts: []Option{WithBoundingBox(-20, 170, -10, -170)},
+		},
+		{
+			name: "no HDOP fields",
+			opts: []Option{WithHDOPFields(), WithStrictHDOPConfig()},
+			want: []string{"invalid Config.HDOPFields: must name at least one field under StrictHDOPConfig, or use IgnoreHDOP"},
+		},
+		{
+			name: "no HDOP fields, not strict",
+			opts: []Option{WithHDOPFields()},
+		},
+		{
+			name: "no HDOP fields, HDOP off",
+			opts: []Option{WithHDOPFields(), WithStrictHDOPConfig(), WithoutHDOP()},
+		},
+		{
+			name: "non-finite thresholds",
+			opts: []Option{WithMaxHDOP(math.NaN()), WithMinDistance(math.Inf(1))},
+			want: []string{
+				"invalid Config.MaxHDOP: must be finite and not negative, got NaN",
+				"invalid Config.MinDistance: must be finite and not negative, got +Inf",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(tt.opts)
+
+			err := cfg.Validate()
+
+			var got []string
+			if err != nil {
+				for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+					got = append(got, e.Error())
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	store, err := New(nil, WithLocationGap(-time.Second))
+
+	assert.Nil(t, store)
+	var cfgErr *ConfigError
+	if assert.ErrorAs(t, err, &cfgErr) {
+		assert.Equal(t, "LocationGap", cfgErr.Field)
+	}
+
+	_, err = NewProcessor(WithCoordinatePrecision(-1))
+	assert.ErrorAs(t, err, &cfgErr)
+
+	assert.Panics(t, func() { MustNewProcessor(WithBoundingBox(43, -84, 42, -83)) })
+
+	_, err = New(nil, WithHDOPFields(""), WithStrictHDOPConfig())
+	if assert.ErrorAs(t, err, &cfgErr) {
+		assert.Equal(t, "HDOPFields", cfgErr.Field)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "github.com/DIMO-Network/model-garage/pkg/vss"
+
+// pairByKey plans a location from each group of signals that share a
+// cfg.CorrelationKey and hold exactly one latitude and one longitude,
+// however far apart they are. An HDOP with the same key, if there is
+// exactly one, is attached in place of the nearest one. The paired
+// signals are marked in prepaired so that the time-based pass skips
+// them; incomplete groups are left to it.
+func (c *Store) pairByKey() {
+	type group struct {
+		lat, lon, hdop []int
+	}
+	var keys []string
+	groups := make(map[string]*group)
+
+	for i, sig := range c.signals {
+		if c.isDropped[i] {
+			continue
+		}
+		isLat := sig.Name == vss.FieldCurrentLocationLatitude
+		isLon := sig.Name == vss.FieldCurrentLocationLongitude
+		isHDOP := c.hdopInline && c.isHDOP(sig.Name)
+		if !isLat && !isLon && !isHDOP {
+			continue
+		}
+
+		key := c.correlationKey(sig)
+		if key == "" {
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		switch {
+		case isLat:
+			g.lat = append(g.lat, i)
+		case isLon:
+			g.lon = append(g.lon, i)
+		default:
+			g.hdop = append(g.hdop, i)
+		}
+	}
+
+	for _, key := range keys {
+		g := groups[key]
+		if len(g.lat) != 1 || len(g.lon) != 1 {
+			continue
+		}
+
+		hdop := -1
+		if len(g.hdop) == 1 {
+			hdop = g.hdop[0]
+		}
+		c.prepair(g.lat[0], g.lon[0], hdop)
+	}
+}
+
+// correlationKey calls cfg.CorrelationKey. If that panics, the signal
+// is taken to have no key.
+func (c *Store) correlationKey(sig vss.Signal) (key string) {
+	defer c.recoverCallback("CorrelationKey")
+	return c.cfg.CorrelationKey(sig)
+}
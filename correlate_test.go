@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelationKey(t *testing.T) {
+	now := time.Now()
+
+	// The trip ID rides in the CloudEvent ID. The first fix's parts
+	// are 2s apart, which is far too far for time-based pairing.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, CloudEventID: "a"},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5, CloudEventID: "a"},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, CloudEventID: "a"},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	actual, err := ProcessSignals(input, WithCorrelationKey(func(sig vss.Signal) string { return sig.CloudEventID }))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}, CloudEventID: "a"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}, CloudEventID: "a"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// SignalDecoder turns a raw payload into signals. The decoders in
+// model-garage fit this with a small adapter; the interface keeps us
+// from depending on any particular version of them.
+type SignalDecoder interface {
+	DecodeSignals(raw []byte) ([]vss.Signal, error)
+}
+
+// DecodeAndProcess decodes raw with decoder and runs the result
+// through ProcessSignals under cfg. If decoding fails then nothing is
+// processed and the decoding error is returned, wrapped. Start from
+// DefaultConfig rather than a zero Config: a Config that fails
+// Config.Validate is rejected with its error before anything is
+// decoded.
+func DecodeAndProcess(raw []byte, decoder SignalDecoder, cfg Config) ([]vss.Signal, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	signals, err := decoder.DecodeSignals(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signals: %w", err)
+	}
+
+	// The decoded slice is ours, so there's no need to copy it.
+	return newStore(signals, cfg).processSignals()
+}
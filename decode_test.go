@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonDecoder stands in for a real payload decoder.
+type jsonDecoder struct{}
+
+func (jsonDecoder) DecodeSignals(raw []byte) ([]vss.Signal, error) {
+	var signals []vss.Signal
+	err := json.Unmarshal(raw, &signals)
+	return signals, err
+}
+
+func TestDecodeAndProcess(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+	raw, err := json.Marshal(input)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	actual, err := DecodeAndProcess(raw, jsonDecoder{}, DefaultConfig())
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestDecodeAndProcessDecodeError(t *testing.T) {
+	actual, err := DecodeAndProcess([]byte("{"), jsonDecoder{}, DefaultConfig())
+
+	var syntaxErr *json.SyntaxError
+	assert.True(t, errors.As(err, &syntaxErr))
+	assert.Nil(t, actual)
+}
+
+func TestDecodeAndProcessInvalidConfig(t *testing.T) {
+	raw := []byte(`[{"tokenId": 3, "name": "speed", "valueNumber": 55}]`)
+
+	actual, err := DecodeAndProcess(raw, jsonDecoder{}, Config{})
+
+	var cfgErr *ConfigError
+	assert.ErrorAs(t, err, &cfgErr)
+	assert.Nil(t, actual)
+}
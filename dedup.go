@@ -0,0 +1,154 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// dropDuplicates drops every signal that is a duplicate of an earlier
+// one, per cfg.DuplicateKey or else an exact copy, and deals with each
+// group of signals that share a TokenID, timestamp, and name but
+// disagree on the value, as described in resolveConflict. It expects
+// the signals to be sorted already, so that any such signals are
+// adjacent.
+func (c *Store) dropDuplicates() {
+	if c.cfg.DedupScope == DedupNone {
+		return
+	}
+
+	start := 0
+	for i := 1; i <= len(c.signals); i++ {
+		if i < len(c.signals) && c.compare(c.signals[start], c.signals[i]) == 0 {
+			continue
+		}
+		// The run is usually a single signal; more than a handful
+		// would be strange.
+		if i-start > 1 && c.inDedupScope(c.signals[start].Name) {
+			c.dedupRun(start, i)
+		}
+		start = i
+	}
+}
+
+// inDedupScope reports whether signals with the given name are to be
+// deduplicated under cfg.DedupScope.
+func (c *Store) inDedupScope(name string) bool {
+	if c.cfg.DedupScope != DedupLocationOnly {
+		return true
+	}
+	switch name {
+	case vss.FieldCurrentLocationLatitude, vss.FieldCurrentLocationLongitude, c.cfg.CoordinatesField:
+		return true
+	}
+	return c.isHDOP(name)
+}
+
+// dedupRun handles the signals between start and end, which all have
+// the same timestamp and name.
+func (c *Store) dedupRun(start, end int) {
+	for i := start + 1; i < end; i++ {
+		for j := start; j < i; j++ {
+			if !c.isDropped[j] && c.isDuplicate(c.signals[i], c.signals[j]) {
+				c.drop(i, ReasonDuplicate)
+				break
+			}
+		}
+	}
+
+	// Group the survivors by TokenID and look for disagreement.
+	var tokens []uint32
+	for i := start; i < end; i++ {
+		if !c.isDropped[i] && !slices.Contains(tokens, c.signals[i].TokenID) {
+			tokens = append(tokens, c.signals[i].TokenID)
+		}
+	}
+
+	for _, token := range tokens {
+		var values []float64
+		for i := start; i < end; i++ {
+			sig := c.signals[i]
+			if !c.isDropped[i] && sig.TokenID == token && !slices.Contains(values, sig.ValueNumber) {
+				values = append(values, sig.ValueNumber)
+			}
+		}
+		if len(values) > 1 {
+			c.resolveConflict(start, end, token, values)
+		}
+	}
+}
+
+// resolveConflict handles the surviving signals for token between start
+// and end, which disagree on the value. For coordinates this follows
+// cfg.ConflictResolution. Otherwise, or by default, they are all kept
+// and a ConflictWarning is returned.
+func (c *Store) resolveConflict(start, end int, token uint32, values []float64) {
+	sig := c.signals[start]
+	res := c.cfg.ConflictResolution
+	if !isCoordinate(sig.Name) {
+		res = ConflictKeepAll
+	}
+
+	if res == ConflictReject {
+		for i := start; i < end; i++ {
+			if !c.isDropped[i] && c.signals[i].TokenID == token {
+				c.drop(i, ReasonConflict)
+			}
+		}
+		c.addErr(&ConflictError{TokenID: token, Name: sig.Name, Time: sig.Timestamp, Values: values})
+		return
+	}
+
+	c.addErr(&ConflictWarning{TokenID: token, Name: sig.Name, Time: sig.Timestamp, Values: values})
+	if res == ConflictKeepAll {
+		return
+	}
+
+	var group []int
+	for i := start; i < end; i++ {
+		if !c.isDropped[i] && c.signals[i].TokenID == token {
+			group = append(group, i)
+		}
+	}
+
+	keep := group[0]
+	switch res {
+	case ConflictLast:
+		keep = group[len(group)-1]
+	case ConflictMean:
+		var sum float64
+		for _, i := range group {
+			sum += c.signals[i].ValueNumber
+		}
+		c.signals[keep].ValueNumber = sum / float64(len(group))
+	}
+
+	for _, i := range group {
+		if i != keep {
+			c.drop(i, ReasonConflict)
+		}
+	}
+}
+
+// isDuplicate reports whether a and b, which have the same timestamp
+// and name, are duplicates.
+func (c *Store) isDuplicate(a, b vss.Signal) bool {
+	if c.cfg.DuplicateKey != nil {
+		return c.sameKey(a, b)
+	}
+	return sameSignal(a, b)
+}
+
+// sameSignal reports whether a and b are identical. We can't use ==
+// because of the time.Time.
+func sameSignal(a, b vss.Signal) bool {
+	return a.TokenID == b.TokenID &&
+		instant(a.Timestamp).Equal(instant(b.Timestamp)) &&
+		a.Name == b.Name &&
+		a.ValueNumber == b.ValueNumber &&
+		a.ValueString == b.ValueString &&
+		a.ValueLocation == b.ValueLocation &&
+		a.Source == b.Source &&
+		a.Producer == b.Producer &&
+		a.CloudEventID == b.CloudEventID
+}
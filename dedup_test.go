@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropExactDuplicates(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 4, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 4, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestDedupScope(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10034.2},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+	loc := vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}}
+
+	tests := []struct {
+		name     string
+		scope    DedupScope
+		expected []vss.Signal
+	}{
+		{"all", DedupAll, []vss.Signal{input[0], input[2], input[4], loc}},
+		{"location only", DedupLocationOnly, []vss.Signal{input[0], input[1], input[2], input[4], loc}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ProcessSignals(input, WithDedupScope(tt.scope))
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDuplicateKey(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 56},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 57},
+		{TokenID: 4, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 58},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldSpeed, ValueNumber: 56},
+	}
+
+	key := func(sig vss.Signal) string {
+		return fmt.Sprintf("%d/%d/%s", sig.TokenID, sig.Timestamp.UnixNano(), sig.Name)
+	}
+
+	actual, err := ProcessSignals(input, WithDuplicateKey(key))
+
+	expected := []vss.Signal{input[0], input[3], input[4]}
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestConflictWarning(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 56},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 57},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	var conflict *ConflictWarning
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, vss.FieldSpeed, conflict.Name)
+		assert.ElementsMatch(t, []float64{55, 56, 57}, conflict.Values)
+	}
+	assert.Len(t, actual, 3)
+}
+
+func TestConflictResolution(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.3},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.1},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.5},
+	}
+
+	location := func(lat float64) vss.Signal {
+		return vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: lat, Longitude: -83.1}}
+	}
+
+	tests := []struct {
+		res      ConflictResolution
+		expected []vss.Signal
+	}{
+		{ConflictFirst, []vss.Signal{input[0], input[1], location(42.3)}},
+		{ConflictLast, []vss.Signal{input[1], input[2], location(42.5)}},
+		{ConflictMean, []vss.Signal{
+			{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.4},
+			input[1],
+			location(42.4),
+		}},
+		{ConflictReject, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint("resolution ", int(tt.res)), func(t *testing.T) {
+			actual, stats, err := ProcessSignalsWithStats(input, WithConflictResolution(tt.res))
+
+			assert.InDeltaSlice(t, latitudes(tt.expected), latitudes(actual), 1e-9)
+			assert.Len(t, actual, len(tt.expected))
+
+			var rejected *ConflictError
+			if tt.res == ConflictReject {
+				if assert.ErrorAs(t, err, &rejected) {
+					assert.ElementsMatch(t, []float64{42.3, 42.5}, rejected.Values)
+				}
+				assert.Equal(t, 2, stats.Dropped[ReasonConflict])
+				assert.Equal(t, 1, stats.Dropped[ReasonUnpaired])
+			} else {
+				assert.False(t, errors.As(err, &rejected))
+				assert.ErrorAs(t, err, new(*ConflictWarning))
+				assert.Equal(t, 1, stats.Dropped[ReasonConflict])
+			}
+		})
+	}
+}
+
+// latitudes returns the latitude of every signal, in sorted order, for
+// comparisons that should tolerate rounding.
+func latitudes(signals []vss.Signal) []float64 {
+	var out []float64
+	for _, s := range signals {
+		switch s.Name {
+		case vss.FieldCurrentLocationLatitude:
+			out = append(out, s.ValueNumber)
+		case fieldCoordinates:
+			out = append(out, s.ValueLocation.Latitude)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
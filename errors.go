@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// categorizedError is implemented by the structured errors that
+// Config.AggregateErrors knows how to group.
+type categorizedError interface {
+	error
+	// category names the kind of problem. For errors that come with
+	// dropped signals, this is the Reason.
+	category() string
+	// at returns the time of the problem.
+	at() time.Time
+}
+
+// errorJSON is the JSON form shared by the structured errors.
+type errorJSON struct {
+	Category string `json:"category"`
+	TokenID  uint32 `json:"tokenId"`
+	// Time is encoded as RFC 3339 with nanoseconds, which
+	// encoding/json does by default.
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// marshalError encodes e as an errorJSON. It takes the TokenID
+// separately since categorizedError has no method for it.
+func marshalError(e categorizedError, tokenID uint32) ([]byte, error) {
+	return json.Marshal(errorJSON{Category: e.category(), TokenID: tokenID, Time: e.at(), Message: e.Error()})
+}
+
+// OriginError is returned when a latitude-longitude pair is dropped
+// for being at (0, 0).
+type OriginError struct {
+	TokenID uint32
+	Time    time.Time
+}
+
+func (e *OriginError) Error() string {
+	return fmt.Sprintf("latitude and longitude at origin at time %s", fmtTime(e.Time))
+}
+
+func (e *OriginError) category() string { return ReasonOrigin }
+func (e *OriginError) at() time.Time    { return e.Time }
+
+func (e *OriginError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// UnpairedError is returned when a latitude or longitude is dropped
+// for lack of a partner.
+type UnpairedError struct {
+	TokenID uint32
+	// Name is the name of the dropped signal.
+	Name string
+	Time time.Time
+}
+
+func (e *UnpairedError) Error() string {
+	if e.Name == vss.FieldCurrentLocationLatitude {
+		return fmt.Sprintf("unpaired latitude at time %s", fmtTime(e.Time))
+	}
+	return fmt.Sprintf("unpaired longitude at time %s", fmtTime(e.Time))
+}
+
+func (e *UnpairedError) category() string { return ReasonUnpaired }
+func (e *UnpairedError) at() time.Time    { return e.Time }
+
+func (e *UnpairedError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// OutOfRangeError is returned when a latitude-longitude pair is
+// dropped because one of the coordinates is out of range.
+type OutOfRangeError struct {
+	TokenID   uint32
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+func (e *OutOfRangeError) Error() string {
+	return fmt.Sprintf("coordinates (%g, %g) out of range at time %s", e.Latitude, e.Longitude, fmtTime(e.Time))
+}
+
+func (e *OutOfRangeError) category() string { return ReasonOutOfRange }
+func (e *OutOfRangeError) at() time.Time    { return e.Time }
+
+func (e *OutOfRangeError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// FutureError is returned when a signal is dropped for having a
+// timestamp too far in the future.
+type FutureError struct {
+	TokenID uint32
+	Name    string
+	Time    time.Time
+}
+
+func (e *FutureError) Error() string {
+	return fmt.Sprintf("signal %s at time %s is too far in the future", e.Name, fmtTime(e.Time))
+}
+
+func (e *FutureError) category() string { return ReasonFuture }
+func (e *FutureError) at() time.Time    { return e.Time }
+
+func (e *FutureError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// PoorFixError is returned when a location is not created because its
+// HDOP is above Config.MaxHDOP.
+type PoorFixError struct {
+	TokenID uint32
+	Time    time.Time
+	HDOP    float64
+	MaxHDOP float64
+}
+
+func (e *PoorFixError) Error() string {
+	return fmt.Sprintf("HDOP %g above maximum %g at time %s", e.HDOP, e.MaxHDOP, fmtTime(e.Time))
+}
+
+func (e *PoorFixError) category() string { return "poor fix" }
+func (e *PoorFixError) at() time.Time    { return e.Time }
+
+func (e *PoorFixError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// OutOfRegionError is returned when a location is not created because
+// it lies outside Config.BoundingBox. The latitude and longitude
+// signals are kept.
+type OutOfRegionError struct {
+	TokenID   uint32
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+func (e *OutOfRegionError) Error() string {
+	return fmt.Sprintf("coordinates (%g, %g) outside bounding box at time %s", e.Latitude, e.Longitude, fmtTime(e.Time))
+}
+
+func (e *OutOfRegionError) category() string { return "out of region" }
+func (e *OutOfRegionError) at() time.Time    { return e.Time }
+
+func (e *OutOfRegionError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// NegativeHDOPError is returned when the HDOP nearest a location is
+// zero, which some devices send to mean that they have no fix. The
+// location is created without an HDOP, and the HDOP signal is kept.
+// Negative HDOPs get an InvalidHDOPError instead, so HDOP is always
+// zero.
+type NegativeHDOPError struct {
+	TokenID uint32
+	// Time is the time of the HDOP signal.
+	Time time.Time
+	HDOP float64
+}
+
+func (e *NegativeHDOPError) Error() string {
+	return fmt.Sprintf("ignoring non-positive HDOP %g at time %s", e.HDOP, fmtTime(e.Time))
+}
+
+func (e *NegativeHDOPError) category() string { return "negative hdop" }
+func (e *NegativeHDOPError) at() time.Time    { return e.Time }
+
+func (e *NegativeHDOPError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// InvalidHDOPError is returned when an HDOP signal is dropped for
+// being negative or not finite. Unlike NegativeHDOPError, this applies
+// to every HDOP, not just those nearest a location. HDOPs supplied
+// separately to ProcessSignalsWithHDOP are only checked when they are
+// nearest a location, and are never dropped.
+type InvalidHDOPError struct {
+	TokenID uint32
+	Name    string
+	Time    time.Time
+	HDOP    float64
+}
+
+func (e *InvalidHDOPError) Error() string {
+	return fmt.Sprintf("invalid HDOP %g in %s at time %s", e.HDOP, e.Name, fmtTime(e.Time))
+}
+
+func (e *InvalidHDOPError) category() string { return ReasonInvalidHDOP }
+func (e *InvalidHDOPError) at() time.Time    { return e.Time }
+
+func (e *InvalidHDOPError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// InvalidSatellitesError is returned under Config.SatellitesField when
+// a satellite count is dropped for being negative or not a whole
+// number.
+type InvalidSatellitesError struct {
+	TokenID uint32
+	Time    time.Time
+	Value   float64
+}
+
+func (e *InvalidSatellitesError) Error() string {
+	return fmt.Sprintf("invalid satellite count %g at time %s", e.Value, fmtTime(e.Time))
+}
+
+func (e *InvalidSatellitesError) category() string { return ReasonInvalidSatellites }
+func (e *InvalidSatellitesError) at() time.Time    { return e.Time }
+
+func (e *InvalidSatellitesError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// SourceMismatchError is returned when a latitude-longitude pair is
+// dropped under Config.SourceConsistency because its halves have
+// different Source fields.
+type SourceMismatchError struct {
+	TokenID         uint32
+	Time            time.Time
+	LatitudeSource  string
+	LongitudeSource string
+}
+
+func (e *SourceMismatchError) Error() string {
+	return fmt.Sprintf("latitude from %q and longitude from %q at time %s", e.LatitudeSource, e.LongitudeSource, fmtTime(e.Time))
+}
+
+func (e *SourceMismatchError) category() string { return ReasonSourceMismatch }
+func (e *SourceMismatchError) at() time.Time    { return e.Time }
+
+func (e *SourceMismatchError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// MissingValueError is returned when a latitude or longitude is
+// dropped because Config.HasValue says it has no value. Its
+// ValueNumber would otherwise be paired as a zero.
+type MissingValueError struct {
+	TokenID uint32
+	Name    string
+	Time    time.Time
+}
+
+func (e *MissingValueError) Error() string {
+	return fmt.Sprintf("signal %s at time %s has no value", e.Name, fmtTime(e.Time))
+}
+
+func (e *MissingValueError) category() string { return ReasonMissingValue }
+func (e *MissingValueError) at() time.Time    { return e.Time }
+
+func (e *MissingValueError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// HDOPMissingError is returned when a latitude-longitude pair is
+// dropped for lack of an HDOP under Config.RequireHDOP.
+type HDOPMissingError struct {
+	TokenID uint32
+	Time    time.Time
+}
+
+func (e *HDOPMissingError) Error() string {
+	return fmt.Sprintf("no HDOP for location at time %s", fmtTime(e.Time))
+}
+
+func (e *HDOPMissingError) category() string { return ReasonHDOPMissing }
+func (e *HDOPMissingError) at() time.Time    { return e.Time }
+
+func (e *HDOPMissingError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// CapExceededError is returned, once per TokenID, when a batch would
+// create more than Config.MaxLocationsPerToken locations for it.
+type CapExceededError struct {
+	TokenID uint32
+	// Time is the time of the first location that was not created.
+	Time time.Time
+	Max  int
+}
+
+func (e *CapExceededError) Error() string {
+	return fmt.Sprintf("more than %d locations for token %d, starting at time %s", e.Max, e.TokenID, fmtTime(e.Time))
+}
+
+func (e *CapExceededError) category() string { return "cap exceeded" }
+func (e *CapExceededError) at() time.Time    { return e.Time }
+
+func (e *CapExceededError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// ConflictWarning is returned when several signals share a TokenID,
+// timestamp, and name, but have different values. All of them are
+// kept, unless Config.ConflictResolution says otherwise.
+type ConflictWarning struct {
+	TokenID uint32
+	Name    string
+	Time    time.Time
+	// Values holds the distinct values that were seen.
+	Values []float64
+}
+
+func (e *ConflictWarning) Error() string {
+	return fmt.Sprintf("conflicting values %v for %s at time %s", e.Values, e.Name, fmtTime(e.Time))
+}
+
+func (e *ConflictWarning) category() string { return "conflict" }
+func (e *ConflictWarning) at() time.Time    { return e.Time }
+
+func (e *ConflictWarning) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// ConflictError is returned under ConflictReject when several
+// latitudes or longitudes share a TokenID and timestamp but have
+// different values. All of them are dropped.
+type ConflictError struct {
+	TokenID uint32
+	Name    string
+	Time    time.Time
+	// Values holds the distinct values that were seen.
+	Values []float64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("rejected conflicting values %v for %s at time %s", e.Values, e.Name, fmtTime(e.Time))
+}
+
+func (e *ConflictError) category() string { return ReasonConflict }
+func (e *ConflictError) at() time.Time    { return e.Time }
+
+func (e *ConflictError) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// BackwardsTimeWarning is returned under Config.CheckTimeOrder for a
+// signal whose timestamp is earlier than that of a signal with the
+// same TokenID that came before it in the input. The signal is still
+// processed in its proper place.
+type BackwardsTimeWarning struct {
+	TokenID uint32
+	Name    string
+	Time    time.Time
+	// Previous is the latest time seen for the TokenID before this
+	// signal.
+	Previous time.Time
+}
+
+func (e *BackwardsTimeWarning) Error() string {
+	return fmt.Sprintf("signal %s at time %s is earlier than previous time %s", e.Name, fmtTime(e.Time), fmtTime(e.Previous))
+}
+
+func (e *BackwardsTimeWarning) category() string { return "backwards time" }
+func (e *BackwardsTimeWarning) at() time.Time    { return e.Time }
+
+func (e *BackwardsTimeWarning) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// FrozenClockWarning is returned under Config.FrozenClockThreshold
+// when a TokenID has many coordinate signals with the same timestamp.
+type FrozenClockWarning struct {
+	TokenID uint32
+	Time    time.Time
+	// Count is the number of latitude and longitude signals at Time.
+	Count int
+}
+
+func (e *FrozenClockWarning) Error() string {
+	return fmt.Sprintf("%d coordinates for token %d at time %s; GPS clock may be stuck", e.Count, e.TokenID, fmtTime(e.Time))
+}
+
+func (e *FrozenClockWarning) category() string { return ReasonFrozenClock }
+func (e *FrozenClockWarning) at() time.Time    { return e.Time }
+
+func (e *FrozenClockWarning) MarshalJSON() ([]byte, error) { return marshalError(e, e.TokenID) }
+
+// ConfigError is returned by Config.Validate for a field with an
+// unusable value.
+type ConfigError struct {
+	// Field is the name of the Config field.
+	Field   string
+	Problem string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid Config.%s: %s", e.Field, e.Problem)
+}
+
+// SuppressedError stands in for the errors beyond Config.MaxErrors.
+type SuppressedError struct {
+	Count int
+}
+
+func (e *SuppressedError) Error() string {
+	return fmt.Sprintf("%d additional errors suppressed", e.Count)
+}
+
+// AggregateError stands in for all the errors of one category when
+// Config.AggregateErrors is set. The individual errors are still
+// available through errors.As.
+type AggregateError struct {
+	Category string
+	// First and Last are the earliest and latest times of the
+	// individual errors.
+	First time.Time
+	Last  time.Time
+	Errs  []error
+}
+
+func (e *AggregateError) Error() string {
+	return fmt.Sprintf("%d %s errors between %s and %s", len(e.Errs), e.Category, fmtTime(e.First), fmtTime(e.Last))
+}
+
+func (e *AggregateError) Unwrap() []error {
+	return e.Errs
+}
+
+func (e *AggregateError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Category string    `json:"category"`
+		Count    int       `json:"count"`
+		First    time.Time `json:"first"`
+		Last     time.Time `json:"last"`
+		Message  string    `json:"message"`
+	}{e.Category, len(e.Errs), e.First, e.Last, e.Error()})
+}
+
+// aggregateErrors replaces all the categorized errors in errs with one
+// AggregateError per category, in order of first appearance. Other
+// errors are left alone.
+func aggregateErrors(errs []error) []error {
+	var out []error
+	var aggs []*AggregateError
+
+	for _, err := range errs {
+		ce, ok := err.(categorizedError)
+		if !ok {
+			out = append(out, err)
+			continue
+		}
+
+		i := slices.IndexFunc(aggs, func(agg *AggregateError) bool { return agg.Category == ce.category() })
+		if i == -1 {
+			agg := &AggregateError{Category: ce.category(), First: ce.at(), Last: ce.at()}
+			aggs = append(aggs, agg)
+			out = append(out, agg)
+			i = len(aggs) - 1
+		}
+
+		agg := aggs[i]
+		agg.Errs = append(agg.Errs, err)
+		if ce.at().Before(agg.First) {
+			agg.First = ce.at()
+		}
+		if ce.at().After(agg.Last) {
+			agg.Last = ce.at()
+		}
+	}
+
+	return out
+}
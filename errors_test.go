@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateErrors(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	var input []vss.Signal
+	for i := range 50 {
+		ts := now.Add(time.Duration(i) * time.Second)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		)
+	}
+	input = append(input,
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Hour), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(2 * time.Hour), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	)
+
+	_, err := ProcessSignals(input, WithAggregateErrors(), WithClock(func() time.Time { return now }), WithMaxFuture(24*time.Hour))
+
+	lines := strings.Split(err.Error(), "\n")
+	assert.Equal(t, []string{
+		"50 origin errors between 2025-08-01T12:00:00Z and 2025-08-01T12:00:49Z",
+		"2 unpaired errors between 2025-08-01T13:00:00Z and 2025-08-01T14:00:00Z",
+	}, lines)
+
+	var origin *OriginError
+	assert.ErrorAs(t, err, &origin)
+
+	var agg *AggregateError
+	if assert.ErrorAs(t, err, &agg) {
+		assert.Len(t, agg.Errs, 50)
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	now := time.Now()
+
+	var input []vss.Signal
+	for i := range 10 {
+		input = append(input, vss.Signal{TokenID: 3, Timestamp: now.Add(time.Duration(i) * time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395})
+	}
+
+	_, stats, err := ProcessSignalsWithStats(input, WithMaxErrors(3))
+
+	lines := strings.Split(err.Error(), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "7 additional errors suppressed", lines[3])
+	}
+	assert.Equal(t, 10, stats.Errors)
+	assert.Equal(t, 10, stats.Dropped[ReasonUnpaired])
+}
+
+func TestAggregateErrorsKeepsOthers(t *testing.T) {
+	errs := aggregateErrors([]error{
+		&UnpairedError{Name: vss.FieldCurrentLocationLatitude},
+		errors.New("something else"),
+		&UnpairedError{Name: vss.FieldCurrentLocationLongitude},
+	})
+
+	if assert.Len(t, errs, 2) {
+		assert.IsType(t, &AggregateError{}, errs[0])
+		assert.EqualError(t, errs[1], "something else")
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	err := &UnpairedError{TokenID: 3, Name: vss.FieldCurrentLocationLatitude, Time: time.Date(2025, 8, 1, 12, 0, 0, 123_000_000, time.UTC)}
+
+	b, jsonErr := json.Marshal(err)
+
+	assert.NoError(t, jsonErr)
+	assert.JSONEq(t, `{
+		"category": "unpaired",
+		"tokenId": 3,
+		"time": "2025-08-01T12:00:00.123Z",
+		"message": "unpaired latitude at time 2025-08-01T12:00:00Z"
+	}`, string(b))
+}
+
+func TestAggregateErrorJSON(t *testing.T) {
+	first := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	last := first.Add(time.Minute)
+	errs := aggregateErrors([]error{
+		&OriginError{TokenID: 3, Time: first},
+		&OriginError{TokenID: 3, Time: last},
+	})
+
+	b, err := json.Marshal(errs[0])
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"category": "origin",
+		"count": 2,
+		"first": "2025-08-01T12:00:00Z",
+		"last": "2025-08-01T12:01:00Z",
+		"message": "2 origin errors between 2025-08-01T12:00:00Z and 2025-08-01T12:01:00Z"
+	}`, string(b))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"unsafe"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// EstimateOutputSize gives an upper bound on the number of signals
+// that ProcessSignals would return for the given input, and on the
+// size in bytes of the returned slice, without doing the processing.
+// Pass the same options that would be given to ProcessSignals.
+//
+// The byte count covers only the slice's backing array. String fields
+// of the created signals share memory with the input.
+func EstimateOutputSize(signals []vss.Signal, opts ...Option) (estimatedSignals int, estimatedBytes int) {
+	cfg := newConfig(opts)
+
+	var lats, lons int
+	for _, sig := range signals {
+		switch sig.Name {
+		case vss.FieldCurrentLocationLatitude:
+			lats++
+		case vss.FieldCurrentLocationLongitude:
+			lons++
+		}
+	}
+
+	// Each location normally uses up one latitude and one longitude.
+	// With last-known pairing, every coordinate can yield one.
+	created := min(lats, lons)
+	if cfg.LastKnownWindow > 0 {
+		created = lats + lons
+	}
+
+	estimatedSignals = len(signals) + created
+
+	// Each of these adds at most one signal per location. The bounding
+	// box is one per TokenID, which is no more than that.
+	for _, on := range []bool{
+		cfg.DistanceField != "",
+		cfg.SatellitesField != "",
+		cfg.AccuracyUERE > 0,
+		cfg.FixTTL > 0,
+		cfg.GapMarkerThreshold > 0,
+		cfg.SmoothingWindow > 0,
+		cfg.BoundingBoxSignal,
+	} {
+		if on {
+			estimatedSignals += created
+		}
+	}
+
+	return estimatedSignals, estimatedSignals * int(unsafe.Sizeof(vss.Signal{}))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateOutputSize(t *testing.T) {
+	input := benchSignals(10_000)
+	clock := WithClock(func() time.Time { return time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC) })
+
+	estSignals, estBytes := EstimateOutputSize(input, clock)
+	actual, _ := ProcessSignals(input, clock)
+
+	// The estimate may be high, but never low, and shouldn't be far
+	// off for typical data.
+	assert.GreaterOrEqual(t, estSignals, len(actual))
+	assert.InEpsilon(t, len(actual), estSignals, 0.15)
+	assert.Equal(t, estSignals*int(unsafe.Sizeof(vss.Signal{})), estBytes)
+}
+
+func TestEstimateOutputSizeDerived(t *testing.T) {
+	// Give each fix a satellite count, and leave some gaps between
+	// them.
+	var input []vss.Signal
+	for i, sig := range benchSignals(2_000) {
+		if i%100 == 0 {
+			sig.Timestamp = sig.Timestamp.Add(time.Hour)
+		}
+		input = append(input, sig)
+		if sig.Name == vss.FieldCurrentLocationLatitude {
+			input = append(input, vss.Signal{TokenID: sig.TokenID, Timestamp: sig.Timestamp, Name: vss.FieldDIMOAftermarketNSAT, ValueNumber: 9})
+		}
+	}
+	clock := WithClock(func() time.Time { return time.Date(2025, 8, 3, 0, 0, 0, 0, time.UTC) })
+
+	options := map[string]Option{
+		"distance":    WithTravelledDistance("odometer", 0),
+		"satellites":  WithSatellites(),
+		"accuracy":    WithAccuracyFromHDOP(5),
+		"expiry":      WithFixTTL(time.Minute),
+		"gaps":        WithGapMarkers(time.Minute),
+		"smoothing":   WithSmoothing(3),
+		"boundingBox": WithBoundingBoxSignal(),
+	}
+
+	all := []Option{clock}
+	for name, opt := range options {
+		t.Run(name, func(t *testing.T) {
+			estSignals, _ := EstimateOutputSize(input, clock, opt)
+			actual, _ := ProcessSignals(input, clock, opt)
+			assert.GreaterOrEqual(t, estSignals, len(actual))
+		})
+		all = append(all, opt)
+	}
+
+	estSignals, _ := EstimateOutputSize(input, all...)
+	actual, _ := ProcessSignals(input, all...)
+	assert.GreaterOrEqual(t, estSignals, len(actual))
+}
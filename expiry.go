@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// fieldLocationExpiry is the name of the signals emitted under
+// Config.FixTTL.
+const fieldLocationExpiry = "currentLocationExpiry"
+
+// expirySignals returns, for each location, a signal whose ValueString
+// is the location's timestamp plus ttl, in RFC 3339 format.
+func expirySignals(locs []vss.Signal, ttl time.Duration) []vss.Signal {
+	out := make([]vss.Signal, 0, len(locs))
+	for _, loc := range locs {
+		out = append(out, vss.Signal{
+			TokenID:      loc.TokenID,
+			Timestamp:    loc.Timestamp,
+			Name:         fieldLocationExpiry,
+			ValueString:  loc.Timestamp.Add(ttl).UTC().Format(time.RFC3339Nano),
+			Source:       loc.Source,
+			Producer:     loc.Producer,
+			CloudEventID: loc.CloudEventID,
+		})
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixTTL(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	actual, err := ProcessSignals(input, WithFixTTL(30*time.Second), WithClock(func() time.Time { return now }), WithMaxFuture(time.Hour))
+
+	// The locations themselves are untouched.
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}},
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldLocationExpiry, ValueString: "2025-08-01T12:00:30Z"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldLocationExpiry, ValueString: "2025-08-01T12:01:30Z"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
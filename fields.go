@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// ErrSuspectFieldName is returned, wrapped, when Config.StrictFields
+// is on and a signal name looks like a misspelled location field.
+var ErrSuspectFieldName = errors.New("signal name looks like a location field")
+
+// maxFieldNameDistance is the largest edit distance at which we
+// consider a name to be a near miss.
+const maxFieldNameDistance = 2
+
+// nearMissFields holds real field names that happen to be near
+// misses for the location fields, and so should not be reported.
+var nearMissFields = map[string]struct{}{
+	vss.FieldCurrentLocationAltitude: {},
+}
+
+// checkFieldName records an error if name is close to, but not the
+// same as, one of the location fields. Each name is only checked once
+// per batch.
+func (c *Store) checkFieldName(name string) {
+	if _, ok := c.checkedNames[name]; ok {
+		return
+	}
+	if c.checkedNames == nil {
+		c.checkedNames = make(map[string]struct{})
+	}
+	c.checkedNames[name] = struct{}{}
+
+	if _, ok := nearMissFields[name]; ok || c.isHDOP(name) {
+		return
+	}
+
+	candidates := append([]string{vss.FieldCurrentLocationLatitude, vss.FieldCurrentLocationLongitude}, c.cfg.HDOPFields...)
+	for _, want := range candidates {
+		if levenshtein(name, want) <= maxFieldNameDistance {
+			c.addErr(fmt.Errorf("%w: %q is close to %q", ErrSuspectFieldName, name, want))
+			return
+		}
+	}
+}
+
+// levenshtein returns the edit distance between a and b, counting
+// bytes rather than runes, which is fine for field names.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
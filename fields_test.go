@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("abc", "abc"))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting"))
+	assert.Equal(t, 1, levenshtein("currentLocationLatitud", vss.FieldCurrentLocationLatitude))
+	assert.Equal(t, 4, levenshtein("", "abcd"))
+}
+
+func TestStrictFields(t *testing.T) {
+	now := time.Now()
+
+	typo := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: "currentLocationLatitud", ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	_, err := ProcessSignals(typo, WithStrictFields())
+	assert.ErrorIs(t, err, ErrSuspectFieldName)
+
+	correct := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationAltitude, ValueNumber: 190},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	_, err = ProcessSignals(correct, WithStrictFields())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,72 @@
+package main
+
+import "github.com/DIMO-Network/model-garage/pkg/vss"
+
+// checkFrozenClock reports a FrozenClockWarning for each TokenID that
+// has at least cfg.FrozenClockThreshold latitude and longitude signals
+// at a single timestamp, which is what a stuck GPS clock looks like.
+// Under cfg.DropFrozen, only one latitude and one longitude from each
+// such group are kept. It expects the signals to be sorted already.
+func (c *Store) checkFrozenClock() {
+	start := 0
+	for i := 1; i <= len(c.signals); i++ {
+		if i < len(c.signals) && instant(c.signals[i].Timestamp).Equal(instant(c.signals[start].Timestamp)) {
+			continue
+		}
+		if i-start >= c.cfg.FrozenClockThreshold {
+			c.frozenRun(start, i)
+		}
+		start = i
+	}
+}
+
+// frozenRun handles the signals between start and end, which all have
+// the same timestamp.
+func (c *Store) frozenRun(start, end int) {
+	// TokenIDs in order of appearance, so that the warnings come out
+	// in a stable order.
+	var tokens []uint32
+	counts := make(map[uint32]int)
+	for i := start; i < end; i++ {
+		sig := c.signals[i]
+		if c.isDropped[i] || !isCoordinate(sig.Name) {
+			continue
+		}
+		if counts[sig.TokenID] == 0 {
+			tokens = append(tokens, sig.TokenID)
+		}
+		counts[sig.TokenID]++
+	}
+
+	for _, token := range tokens {
+		if counts[token] < c.cfg.FrozenClockThreshold {
+			continue
+		}
+		c.addErr(&FrozenClockWarning{TokenID: token, Time: c.signals[start].Timestamp, Count: counts[token]})
+
+		if !c.cfg.DropFrozen {
+			continue
+		}
+		var keptLat, keptLon bool
+		for i := start; i < end; i++ {
+			sig := c.signals[i]
+			if c.isDropped[i] || sig.TokenID != token {
+				continue
+			}
+			switch {
+			case sig.Name == vss.FieldCurrentLocationLatitude && !keptLat:
+				keptLat = true
+			case sig.Name == vss.FieldCurrentLocationLongitude && !keptLon:
+				keptLon = true
+			case isCoordinate(sig.Name):
+				c.drop(i, ReasonFrozenClock)
+			}
+		}
+	}
+}
+
+// isCoordinate reports whether name is that of a latitude or longitude
+// signal.
+func isCoordinate(name string) bool {
+	return name == vss.FieldCurrentLocationLatitude || name == vss.FieldCurrentLocationLongitude
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func frozenSignals(now time.Time) []vss.Signal {
+	return []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.3371},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+}
+
+func TestFrozenClockWarning(t *testing.T) {
+	now := time.Now()
+
+	_, err := ProcessSignals(frozenSignals(now), WithFrozenClockDetection(5, false))
+
+	var frozen *FrozenClockWarning
+	if assert.ErrorAs(t, err, &frozen) {
+		assert.Equal(t, 5, frozen.Count)
+		assert.Equal(t, now, frozen.Time)
+	}
+}
+
+func TestFrozenClockBelowThreshold(t *testing.T) {
+	now := time.Now()
+
+	_, err := ProcessSignals(frozenSignals(now), WithFrozenClockDetection(6, false))
+
+	var frozen *FrozenClockWarning
+	assert.False(t, errors.As(err, &frozen))
+}
+
+func TestFrozenClockDrop(t *testing.T) {
+	now := time.Now()
+
+	res, err := ProcessSignalsVerbose(frozenSignals(now), WithFrozenClockDetection(5, true))
+
+	assert.Error(t, err)
+	assert.Len(t, res.Created, 1)
+	assert.Len(t, res.Dropped, 3)
+	// One latitude, one longitude, the speed, and the location.
+	assert.Len(t, res.Signals, 4)
+}
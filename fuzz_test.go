@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// fuzzNames are the names that fuzzSignals draws from. The location
+// fields are overrepresented so that pairing gets exercised.
+var fuzzNames = []string{
+	vss.FieldCurrentLocationLatitude,
+	vss.FieldCurrentLocationLongitude,
+	vss.FieldCurrentLocationLatitude,
+	vss.FieldCurrentLocationLongitude,
+	vss.FieldDIMOAftermarketHDOP,
+	fieldCoordinates,
+	vss.FieldSpeed,
+	"__drop",
+}
+
+// fuzzSignalSize is the number of bytes that fuzzSignals consumes per
+// signal: one each for the TokenID and name, two for the time offset
+// in milliseconds, and eight each for the number and the second
+// coordinate.
+const fuzzSignalSize = 20
+
+// fuzzSignals decodes data into signals. Timestamps fall within about
+// a minute, so that many of them are close enough to pair.
+func fuzzSignals(data []byte) []vss.Signal {
+	start := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	signals := []vss.Signal{}
+	for ; len(data) >= fuzzSignalSize; data = data[fuzzSignalSize:] {
+		sig := vss.Signal{
+			TokenID:     uint32(data[0] % 3),
+			Name:        fuzzNames[int(data[1])%len(fuzzNames)],
+			Timestamp:   start.Add(time.Duration(binary.LittleEndian.Uint16(data[2:])) * time.Millisecond),
+			ValueNumber: math.Float64frombits(binary.LittleEndian.Uint64(data[4:])),
+		}
+		if sig.Name == fieldCoordinates {
+			sig.ValueLocation = vss.Location{
+				Latitude:  sig.ValueNumber,
+				Longitude: math.Float64frombits(binary.LittleEndian.Uint64(data[12:])),
+			}
+			sig.ValueNumber = 0
+		}
+		signals = append(signals, sig)
+	}
+	return signals
+}
+
+func FuzzProcessSignals(f *testing.F) {
+	seed := func(sigs ...[]byte) []byte {
+		var out []byte
+		for _, s := range sigs {
+			out = append(out, s...)
+		}
+		return out
+	}
+	sig := func(name byte, ms uint16, value, second float64) []byte {
+		b := make([]byte, fuzzSignalSize)
+		b[1] = name
+		binary.LittleEndian.PutUint16(b[2:], ms)
+		binary.LittleEndian.PutUint64(b[4:], math.Float64bits(value))
+		binary.LittleEndian.PutUint64(b[12:], math.Float64bits(second))
+		return b
+	}
+
+	f.Add(seed(sig(0, 0, 42.33, 0), sig(1, 100, -83.06, 0), sig(4, 50, 1.2, 0)))
+	f.Add(seed(sig(4, 0, 1.2, 0), sig(4, 100, 1.5, 0)))
+	f.Add(seed(sig(0, 0, 0, 0), sig(1, 0, 0, 0), sig(5, 10, 0, 0)))
+	f.Add(seed(sig(0, 0, 95, 0), sig(1, 10, math.NaN(), 0), sig(5, 1000, 42.33, math.Inf(1))))
+	f.Add(seed(sig(0, 0, 42.33, 0), sig(0, 0, 42.34, 0), sig(1, 0, -83.06, 0), sig(7, 0, 1, 0)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input := fuzzSignals(data)
+		clock := WithClock(func() time.Time { return time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC) })
+
+		out, _ := ProcessSignals(input, clock)
+
+		inputSentinels := 0
+		for _, sig := range input {
+			if sig.Name == "__drop" {
+				inputSentinels++
+			}
+		}
+
+		outputSentinels := 0
+		for _, sig := range out {
+			switch sig.Name {
+			case "__drop":
+				outputSentinels++
+			case fieldCoordinates:
+				loc := sig.ValueLocation
+				if math.IsNaN(loc.Latitude) || math.IsInf(loc.Latitude, 0) || math.IsNaN(loc.Longitude) || math.IsInf(loc.Longitude, 0) {
+					t.Errorf("non-finite location %v", loc)
+				}
+				if loc.Latitude == 0 && loc.Longitude == 0 {
+					t.Errorf("location at origin at %s", sig.Timestamp)
+				}
+			}
+		}
+		if outputSentinels > inputSentinels {
+			t.Errorf("%d signals named __drop in the output, but only %d in the input", outputSentinels, inputSentinels)
+		}
+	})
+}
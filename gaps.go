@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// fieldLocationGap is the name of the markers emitted under
+// Config.GapMarkerThreshold.
+const fieldLocationGap = "currentLocationGap"
+
+// gapMarkers returns a marker for each pair of consecutive locations
+// of a TokenID that are more than threshold apart. Each marker is at
+// the time of the earlier location, and its value is the length of the
+// gap in seconds. The locations are assumed to be in time order.
+func gapMarkers(locs []vss.Signal, threshold time.Duration) []vss.Signal {
+	var out []vss.Signal
+
+	prev := make(map[uint32]vss.Signal)
+	for _, loc := range locs {
+		if p, ok := prev[loc.TokenID]; ok {
+			if gap := instant(loc.Timestamp).Sub(instant(p.Timestamp)); gap > threshold {
+				out = append(out, vss.Signal{
+					TokenID:      p.TokenID,
+					Timestamp:    p.Timestamp,
+					Name:         fieldLocationGap,
+					ValueNumber:  gap.Seconds(),
+					Source:       p.Source,
+					Producer:     p.Producer,
+					CloudEventID: p.CloudEventID,
+				})
+			}
+		}
+		prev[loc.TokenID] = loc
+	}
+
+	return out
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// earthRadius is the mean radius of the Earth in meters.
+const earthRadius = 6371008.8
+
+// ErrSuspectUnits is returned, wrapped, when Config.UnitSanityCheck
+// is on and the coordinates in a batch look like radians.
+var ErrSuspectUnits = errors.New("coordinates may not be in decimal degrees")
+
+// suspectUnitsSpread is the largest spread in coordinate values, in
+// either axis, that we consider suspiciously small. In degrees this is
+// around a kilometer; in radians it's more like sixty.
+const suspectUnitsSpread = 0.01
+
+// checkUnits makes a rough guess as to whether the given locations
+// have coordinates in radians instead of degrees. We can't really
+// know, but a batch where every latitude is within ±π/2, every
+// longitude is within ±π, and the whole track fits in a tiny box is
+// suspicious. Real tracks near (0, 0) will also trip this, which is
+// why it is opt-in.
+func checkUnits(locs []vss.Signal) error {
+	if len(locs) == 0 {
+		return nil
+	}
+
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, loc := range locs {
+		lat, lon := loc.ValueLocation.Latitude, loc.ValueLocation.Longitude
+		if math.Abs(lat) > math.Pi/2 || math.Abs(lon) > math.Pi {
+			return nil
+		}
+		minLat, maxLat = min(minLat, lat), max(maxLat, lat)
+		minLon, maxLon = min(minLon, lon), max(maxLon, lon)
+	}
+
+	if maxLat-minLat > suspectUnitsSpread || maxLon-minLon > suspectUnitsSpread {
+		return nil
+	}
+
+	return fmt.Errorf("%w: all %d locations lie in the box (%g, %g)-(%g, %g)", ErrSuspectUnits, len(locs), minLat, minLon, maxLat, maxLon)
+}
+
+// BoundingBox is a region bounded by lines of latitude and longitude,
+// edges included. If MinLon is greater than MaxLon then the box
+// crosses the antimeridian, running east from MinLon to MaxLon.
+type BoundingBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// Contains reports whether the point is in the box.
+func (b *BoundingBox) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return b.MinLon <= lon && lon <= b.MaxLon
+	}
+	return lon >= b.MinLon || lon <= b.MaxLon
+}
+
+// wrapLongitude maps a longitude in degrees into [-180, 180). For
+// example, 280 becomes -80.
+func wrapLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
+
+// roundHalfEven rounds x to the given number of decimal places, with
+// ties going to the even digit so that rounding doesn't drift in one
+// direction.
+func roundHalfEven(x float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.RoundToEven(x*scale) / scale
+}
+
+// DistanceFunc returns the distance in meters between two points
+// given in decimal degrees.
+type DistanceFunc func(lat1, lon1, lat2, lon2 float64) float64
+
+// Haversine returns the great-circle distance in meters between two
+// points given in decimal degrees. It is the default DistanceFunc.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// Equirectangular is a DistanceFunc that approximates the distance
+// between two points by projecting them onto a plane at their mean
+// latitude. It is several times faster than Haversine and well
+// within a meter of it for points a few kilometers apart, which
+// covers consecutive fixes from a vehicle. The error grows with
+// distance, to around one percent across a continent. It does not
+// handle pairs that straddle the antimeridian.
+func Equirectangular(lat1, lon1, lat2, lon2 float64) float64 {
+	x := (lon2 - lon1) * math.Pi / 180 * math.Cos((lat1+lat2)/2*math.Pi/180)
+	y := (lat2 - lat1) * math.Pi / 180
+	return earthRadius * math.Hypot(x, y)
+}
+
+// thinByDistance removes locations so that, for each TokenID,
+// consecutive surviving locations are at least minDist meters apart,
+// as measured by dist. The first and last location for each TokenID
+// are always kept. The locations are assumed to be in time order, and
+// the slice is filtered in place.
+func thinByDistance(locs []vss.Signal, minDist float64, dist DistanceFunc) []vss.Signal {
+	// For each TokenID, the index of the last kept location and of
+	// the final location overall.
+	lastKept := make(map[uint32]int)
+	final := make(map[uint32]int)
+	for i, loc := range locs {
+		final[loc.TokenID] = i
+	}
+
+	keep := make([]bool, len(locs))
+	for i, loc := range locs {
+		prev, ok := lastKept[loc.TokenID]
+		if ok && i != final[loc.TokenID] {
+			p := locs[prev].ValueLocation
+			if dist(p.Latitude, p.Longitude, loc.ValueLocation.Latitude, loc.ValueLocation.Longitude) < minDist {
+				continue
+			}
+		}
+		keep[i] = true
+		lastKept[loc.TokenID] = i
+	}
+
+	out := locs[:0]
+	for i, loc := range locs {
+		if keep[i] {
+			out = append(out, loc)
+		}
+	}
+	return out
+}
+
+// travelledDistance computes, for each location, the total distance
+// in kilometers travelled by its TokenID up to that point, starting
+// from offset. The locations are assumed to be in time order. The
+// results are signals with the given name.
+func travelledDistance(locs []vss.Signal, name string, offset float64, dist DistanceFunc) []vss.Signal {
+	out := make([]vss.Signal, 0, len(locs))
+
+	prev := make(map[uint32]vss.Location)
+	total := make(map[uint32]float64)
+	for _, loc := range locs {
+		cur := loc.ValueLocation
+		acc, ok := total[loc.TokenID]
+		if !ok {
+			acc = offset
+		} else {
+			p := prev[loc.TokenID]
+			acc += dist(p.Latitude, p.Longitude, cur.Latitude, cur.Longitude) / 1000
+		}
+		prev[loc.TokenID] = cur
+		total[loc.TokenID] = acc
+
+		out = append(out, vss.Signal{
+			TokenID:      loc.TokenID,
+			Timestamp:    loc.Timestamp,
+			Name:         name,
+			ValueNumber:  acc,
+			Source:       loc.Source,
+			Producer:     loc.Producer,
+			CloudEventID: loc.CloudEventID,
+		})
+	}
+
+	return out
+}
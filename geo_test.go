@@ -0,0 +1,350 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHaversine(t *testing.T) {
+	// One degree of latitude is about 111.2 km everywhere.
+	assert.InDelta(t, 111195, Haversine(0, 0, 1, 0), 1)
+	assert.Zero(t, Haversine(42.3, -83.0, 42.3, -83.0))
+}
+
+func TestMinDistance(t *testing.T) {
+	now := time.Now()
+
+	// Points due north along a meridian, about 11.1 m apart.
+	const step = 0.0001
+
+	var input []vss.Signal
+	for i := range 10 {
+		ts := now.Add(time.Duration(i) * time.Second)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42 + float64(i)*step},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83},
+		)
+	}
+
+	actual, err := ProcessSignals(input, WithMinDistance(30))
+
+	var lats []float64
+	for _, sig := range actual {
+		if sig.Name == fieldCoordinates {
+			lats = append(lats, sig.ValueLocation.Latitude)
+		}
+	}
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{42, 42 + 3*step, 42 + 6*step, 42 + 9*step}, lats, 1e-9)
+	assert.Len(t, actual, 20+4)
+}
+
+func TestUnitSanityCheck(t *testing.T) {
+	now := time.Now()
+
+	degrees := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	radians := make([]vss.Signal, len(degrees))
+	for i, sig := range degrees {
+		sig.ValueNumber *= math.Pi / 180
+		radians[i] = sig
+	}
+
+	_, err := ProcessSignals(degrees, WithUnitSanityCheck())
+	assert.NoError(t, err)
+
+	_, err = ProcessSignals(radians, WithUnitSanityCheck())
+	assert.ErrorIs(t, err, ErrSuspectUnits)
+
+	_, err = ProcessSignals(radians)
+	assert.NoError(t, err)
+}
+
+func TestTravelledDistance(t *testing.T) {
+	now := time.Now()
+
+	// Out one degree of latitude, across one degree of longitude on
+	// the equator, and back again to the start: three legs of about
+	// 111.2 km plus the hypotenuse.
+	path := [][2]float64{{1, 1}, {2, 1}, {2, 2}, {1, 1}}
+
+	var input []vss.Signal
+	for i, p := range path {
+		ts := now.Add(time.Duration(i) * time.Minute)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: p[0]},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: p[1]},
+		)
+	}
+
+	res, err := ProcessSignalsVerbose(input, WithTravelledDistance("derivedTravelledDistance", 1000))
+
+	var got []float64
+	for _, sig := range res.Derived {
+		assert.Equal(t, "derivedTravelledDistance", sig.Name)
+		got = append(got, sig.ValueNumber)
+	}
+
+	leg1 := Haversine(1, 1, 2, 1) / 1000
+	leg2 := Haversine(2, 1, 2, 2) / 1000
+	leg3 := Haversine(2, 2, 1, 1) / 1000
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{1000, 1000 + leg1, 1000 + leg1 + leg2, 1000 + leg1 + leg2 + leg3}, got, 1e-9)
+	assert.InDelta(t, 111.2, leg1, 0.1)
+	assert.InDelta(t, 111.1, leg2, 0.1)
+	assert.InDelta(t, 157.2, leg3, 0.1)
+	assert.Len(t, res.Signals, 8+4+4)
+}
+
+func TestWrapLongitude(t *testing.T) {
+	assert.Equal(t, -80.0, wrapLongitude(280))
+	assert.Equal(t, -170.0, wrapLongitude(190))
+	assert.Equal(t, -83.0, wrapLongitude(-83))
+	assert.Equal(t, 179.0, wrapLongitude(-181))
+}
+
+func TestOutOfRangeCoordinates(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 280},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 95},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	assert.Error(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestWrapLongitudeOption(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 280},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: -33.8568},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 190},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 95},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 10},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -80},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: -33.8568},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -170},
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -80}},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: -33.8568, Longitude: -170}},
+	}
+
+	actual, err := ProcessSignals(input, WithWrapLongitude())
+
+	assert.Error(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestAntimeridian(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Antimeridian
+		lon      float64
+		expected float64
+	}{
+		{"as is east", AntimeridianAsIs, 180, 180},
+		{"as is west", AntimeridianAsIs, -180, -180},
+		{"west east", AntimeridianWest, 180, -180},
+		{"west west", AntimeridianWest, -180, -180},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+
+			input := []vss.Signal{
+				{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: -16.5},
+				{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: tt.lon},
+			}
+
+			actual, err := ProcessSignals(input, WithAntimeridian(tt.policy))
+
+			expected := append(slices.Clone(input), vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: -16.5, Longitude: tt.expected}})
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expected, actual)
+		})
+	}
+}
+
+func TestAntimeridianAfterRounding(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: -16.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 179.9999999},
+	}
+
+	actual, err := ProcessSignals(input, WithCoordinatePrecision(5), WithAntimeridian(AntimeridianWest))
+
+	expected := append(slices.Clone(input), vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: -16.5, Longitude: -180}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestBoundingBox(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 41.8781},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -87.6298},
+	}
+
+	// Roughly southeast Michigan.
+	actual, err := ProcessSignals(input, WithBoundingBox(41.7, -84.5, 43.5, -82.4))
+
+	expected := append(slices.Clone(input),
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	var outOfRegion *OutOfRegionError
+	if assert.ErrorAs(t, err, &outOfRegion) {
+		assert.Equal(t, -87.6298, outOfRegion.Longitude)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestBoundingBoxContains(t *testing.T) {
+	// Fiji straddles the antimeridian.
+	fiji := BoundingBox{MinLat: -21, MinLon: 176, MaxLat: -12, MaxLon: -178}
+
+	assert.True(t, fiji.Contains(-18, 178))
+	assert.True(t, fiji.Contains(-18, -179))
+	assert.True(t, fiji.Contains(-18, 180))
+	assert.False(t, fiji.Contains(-18, 0))
+	assert.False(t, fiji.Contains(-30, 178))
+
+	michigan := BoundingBox{MinLat: 41.7, MinLon: -90.4, MaxLat: 48.3, MaxLon: -82.4}
+
+	assert.True(t, michigan.Contains(42.3, -83.0))
+	assert.False(t, michigan.Contains(42.3, -80))
+}
+
+func TestRoundHalfEven(t *testing.T) {
+	assert.Equal(t, 0.12, roundHalfEven(0.125, 2))
+	assert.Equal(t, 0.38, roundHalfEven(0.375, 2))
+	assert.Equal(t, -83.06029, roundHalfEven(-83.06028627110183, 5))
+}
+
+func TestCoordinatePrecision(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input, WithCoordinatePrecision(5))
+
+	// Only the created location is rounded.
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33433, Longitude: -83.06029}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestCoordinatePrecisionChecked(t *testing.T) {
+	now := time.Now()
+
+	// Valid as sent, but (0, 0) once rounded.
+	origin := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0.000004},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0.000004},
+	}
+
+	actual, err := ProcessSignals(origin, WithCoordinatePrecision(5))
+
+	var originErr *OriginError
+	assert.ErrorAs(t, err, &originErr)
+	assert.Empty(t, actual)
+
+	// Inside the box as sent, but on the wrong side of its edge once
+	// rounded.
+	edge := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.000004},
+	}
+	actual, err = ProcessSignals(edge, WithCoordinatePrecision(5), WithBoundingBox(41.7, -90.4, 48.3, -83.000002))
+
+	var region *OutOfRegionError
+	assert.ErrorAs(t, err, &region)
+	assert.ElementsMatch(t, edge, actual)
+}
+
+func TestDistanceFuncs(t *testing.T) {
+	cities := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		// km is the great-circle distance.
+		km float64
+	}{
+		{"London-Paris", 51.5074, -0.1278, 48.8566, 2.3522, 343.6},
+		{"New York-Los Angeles", 40.7128, -74.0060, 34.0522, -118.2437, 3935.7},
+		{"Detroit-Ann Arbor", 42.3314, -83.0458, 42.2808, -83.7430, 57.6},
+	}
+
+	for _, c := range cities {
+		t.Run(c.name, func(t *testing.T) {
+			// Haversine should be within 0.1 km, and Equirectangular
+			// within 2%.
+			assert.InDelta(t, c.km, Haversine(c.lat1, c.lon1, c.lat2, c.lon2)/1000, 0.1)
+			assert.InEpsilon(t, c.km, Equirectangular(c.lat1, c.lon1, c.lat2, c.lon2)/1000, 0.02)
+		})
+	}
+}
+
+func TestReproject(t *testing.T) {
+	now := time.Now()
+
+	// A site grid in meters, with its origin at a known point.
+	const originLat, originLon = 42.3314, -83.0458
+	reproject := func(x, y float64) (lat, lon float64) {
+		return originLat + y/111_000, originLon + x/82_000
+	}
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 1110},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -820},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+	}
+
+	res, err := ProcessSignalsVerbose(input, WithReproject(reproject))
+
+	// Without reprojection, the first would be out of range and the
+	// second at the origin.
+	assert.NoError(t, err)
+	if assert.Len(t, res.Created, 2) {
+		assert.InDelta(t, 42.3414, res.Created[0].ValueLocation.Latitude, 1e-9)
+		assert.InDelta(t, -83.0558, res.Created[0].ValueLocation.Longitude, 1e-9)
+		assert.Equal(t, vss.Location{Latitude: originLat, Longitude: originLon}, res.Created[1].ValueLocation)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// knownCoordinates holds the most recent usable latitude and longitude
+// for a TokenID, for Config.LastKnownWindow.
+type knownCoordinates struct {
+	lat, lon         float64
+	latTime, lonTime time.Time
+	hasLat, hasLon   bool
+}
+
+// lastKnownLocation tries to complete an active pair that has only a
+// latitude or only a longitude with the last known value of the other
+// coordinate. It reports false if that isn't enabled, if there is no
+// such value within cfg.LastKnownWindow, or if the result would fail
+// the origin and range checks.
+func (c *Store) lastKnownLocation() (vss.Location, bool) {
+	if c.cfg.LastKnownWindow <= 0 || c.lastStart == -1 {
+		return vss.Location{}, false
+	}
+
+	lone := c.signals[c.lastStart]
+	known, ok := c.lastKnown[lone.TokenID]
+	if !ok {
+		return vss.Location{}, false
+	}
+
+	var loc vss.Location
+	var other time.Time
+	if c.lastLat != -1 {
+		if !known.hasLon {
+			return vss.Location{}, false
+		}
+		loc = vss.Location{Latitude: lone.ValueNumber, Longitude: known.lon}
+		other = known.lonTime
+	} else {
+		if !known.hasLat {
+			return vss.Location{}, false
+		}
+		loc = vss.Location{Latitude: known.lat, Longitude: lone.ValueNumber}
+		other = known.latTime
+	}
+
+	if instant(lone.Timestamp).Sub(instant(other)) >= c.cfg.LastKnownWindow {
+		return vss.Location{}, false
+	}
+	loc = c.round(c.reproject(loc))
+	if checkCoordinates(lone.TokenID, lone.Timestamp, loc) != nil {
+		return vss.Location{}, false
+	}
+
+	return loc, true
+}
+
+// rememberCoordinates records the members of the active pair as the
+// last known coordinates for their TokenID. Members that were dropped
+// are skipped, unless they were alone and in range: those are exactly
+// the ones that a later lone coordinate might pair with.
+func (c *Store) rememberCoordinates() {
+	lone := c.lastLat == -1 || c.lastLon == -1
+
+	if i := c.lastLat; i != -1 {
+		sig := c.signals[i]
+		if !c.isDropped[i] || lone && -90 <= sig.ValueNumber && sig.ValueNumber <= 90 {
+			known := c.known(sig.TokenID)
+			known.lat, known.latTime, known.hasLat = sig.ValueNumber, sig.Timestamp, true
+			c.lastKnown[sig.TokenID] = known
+		}
+	}
+
+	if i := c.lastLon; i != -1 {
+		sig := c.signals[i]
+		if !c.isDropped[i] || lone && -180 <= sig.ValueNumber && sig.ValueNumber <= 180 {
+			known := c.known(sig.TokenID)
+			known.lon, known.lonTime, known.hasLon = sig.ValueNumber, sig.Timestamp, true
+			c.lastKnown[sig.TokenID] = known
+		}
+	}
+}
+
+// known returns the last known coordinates for the given TokenID,
+// allocating the map if needed.
+func (c *Store) known(tokenID uint32) knownCoordinates {
+	if c.lastKnown == nil {
+		c.lastKnown = make(map[uint32]knownCoordinates)
+	}
+	return c.lastKnown[tokenID]
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastKnownPairing(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(3 * time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	actual, err := ProcessSignals(input, WithLastKnownPairing(5*time.Second, func(sig *vss.Signal) {
+		sig.Producer = "last-known"
+	}))
+
+	// The first latitude has nothing to pair with, but each signal
+	// after it can use the one before.
+	expected := append(input[1:],
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, Producer: "last-known"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(2 * time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.06028627110183}, Producer: "last-known"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(3 * time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}, Producer: "last-known"},
+	)
+
+	var unpaired *UnpairedError
+	if assert.ErrorAs(t, err, &unpaired) {
+		assert.Equal(t, now, unpaired.Time)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestLastKnownPairingOutsideWindow(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(10 * time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 4, Timestamp: now.Add(11 * time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+	}
+
+	actual, err := ProcessSignals(input, WithLastKnownPairing(5*time.Second, nil))
+
+	// Too far apart, and different vehicles.
+	assert.Error(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestLastKnownPairingNotAtOrigin(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+	}
+
+	actual, err := ProcessSignals(input, WithLastKnownPairing(5*time.Second, nil))
+
+	assert.Error(t, err)
+	assert.Empty(t, actual)
+}
@@ -0,0 +1,127 @@
+// Package locgentest generates synthetic signal streams for testing
+// code that consumes locgen.
+package locgentest
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// TrackOptions controls GenerateTrack. The zero value gives a short,
+// clean track for token 1 starting in Detroit.
+type TrackOptions struct {
+	// Seed makes the track reproducible. Equal options give equal
+	// tracks.
+	Seed uint64
+	// TokenID is stamped on every signal.
+	TokenID uint32
+	// Start is the time of the first fix. It defaults to
+	// 2025-08-01T12:00:00Z.
+	Start time.Time
+	// StartLatitude and StartLongitude are the first fix. If both are
+	// zero then the track starts in Detroit.
+	StartLatitude  float64
+	StartLongitude float64
+
+	// Fixes is the number of latitude-longitude-HDOP triples. It
+	// defaults to 10.
+	Fixes int
+	// Interval is the time between fixes. It defaults to a second.
+	Interval time.Duration
+	// Skew, if positive, is the most by which the longitude and HDOP
+	// of a fix may trail its latitude.
+	Skew time.Duration
+
+	// Duplicates is the number of exact copies of earlier signals to
+	// insert.
+	Duplicates int
+	// OriginFixes is the number of fixes to replace with ones at
+	// (0, 0).
+	OriginFixes int
+	// Gaps is the number of times to leave GapLength of silence
+	// between two fixes. GapLength defaults to ten minutes.
+	Gaps      int
+	GapLength time.Duration
+}
+
+// GenerateTrack returns the signals for a vehicle wandering along a
+// random path, in time order apart from the inserted duplicates, each
+// of which is placed right after the fix it copies.
+func GenerateTrack(opts TrackOptions) []vss.Signal {
+	if opts.Start.IsZero() {
+		opts.Start = time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	}
+	if opts.StartLatitude == 0 && opts.StartLongitude == 0 {
+		opts.StartLatitude, opts.StartLongitude = 42.3314, -83.0458
+	}
+	if opts.TokenID == 0 {
+		opts.TokenID = 1
+	}
+	if opts.Fixes <= 0 {
+		opts.Fixes = 10
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.GapLength <= 0 {
+		opts.GapLength = 10 * time.Minute
+	}
+
+	r := rand.New(rand.NewPCG(opts.Seed, 617))
+
+	origin := pick(r, opts.Fixes, opts.OriginFixes)
+	// A gap goes before a fix, so never before the first.
+	gaps := pick(r, opts.Fixes-1, opts.Gaps)
+	dups := pick(r, opts.Fixes, opts.Duplicates)
+
+	skew := func() time.Duration {
+		if opts.Skew <= 0 {
+			return 0
+		}
+		return time.Duration(r.Int64N(int64(opts.Skew) + 1))
+	}
+
+	var out []vss.Signal
+	t := opts.Start
+	lat, lon := opts.StartLatitude, opts.StartLongitude
+
+	for i := range opts.Fixes {
+		if i > 0 {
+			t = t.Add(opts.Interval)
+			if gaps[i-1] {
+				t = t.Add(opts.GapLength)
+			}
+			lat += (r.Float64() - 0.5) / 1000
+			lon += (r.Float64() - 0.5) / 1000
+		}
+
+		fixLat, fixLon := lat, lon
+		if origin[i] {
+			fixLat, fixLon = 0, 0
+		}
+
+		fix := []vss.Signal{
+			{TokenID: opts.TokenID, Timestamp: t, Name: vss.FieldCurrentLocationLatitude, ValueNumber: fixLat},
+			{TokenID: opts.TokenID, Timestamp: t.Add(skew()), Name: vss.FieldCurrentLocationLongitude, ValueNumber: fixLon},
+			{TokenID: opts.TokenID, Timestamp: t.Add(skew()), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1 + 4*r.Float64()},
+		}
+		out = append(out, fix...)
+		if dups[i] {
+			out = append(out, fix[r.IntN(len(fix))])
+		}
+	}
+
+	return out
+}
+
+// pick chooses k distinct indices out of n, or all of them if k is at
+// least n, and returns a membership slice of length n.
+func pick(r *rand.Rand, n, k int) []bool {
+	chosen := make([]bool, max(n, 0))
+	for _, i := range r.Perm(len(chosen))[:min(max(k, 0), len(chosen))] {
+		chosen[i] = true
+	}
+	return chosen
+}
@@ -0,0 +1,65 @@
+package locgentest
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTrackClean(t *testing.T) {
+	track := GenerateTrack(TrackOptions{Seed: 1})
+
+	assert.Len(t, track, 30)
+	for i, sig := range track {
+		assert.Equal(t, uint32(1), sig.TokenID)
+		if i > 0 {
+			assert.False(t, sig.Timestamp.Before(track[i-1].Timestamp), "signal %d is out of order", i)
+		}
+	}
+}
+
+func TestGenerateTrackDeterministic(t *testing.T) {
+	opts := TrackOptions{Seed: 7, Fixes: 50, Skew: 50 * time.Millisecond, Duplicates: 3, OriginFixes: 2, Gaps: 2}
+
+	assert.Equal(t, GenerateTrack(opts), GenerateTrack(opts))
+}
+
+func TestGenerateTrackAnomalies(t *testing.T) {
+	opts := TrackOptions{Seed: 7, Fixes: 50, Duplicates: 3, OriginFixes: 2, Gaps: 2, GapLength: time.Hour}
+
+	track := GenerateTrack(opts)
+
+	assert.Len(t, track, 3*50+3)
+
+	duplicates := 0
+	for i := range track {
+		if slices.Contains(track[:i], track[i]) {
+			duplicates++
+		}
+	}
+	assert.Equal(t, 3, duplicates)
+
+	origins := 0
+	for _, sig := range track {
+		if sig.Name == vss.FieldCurrentLocationLatitude && sig.ValueNumber == 0 {
+			origins++
+		}
+	}
+	assert.Equal(t, 2, origins)
+
+	gaps := 0
+	var last time.Time
+	for _, sig := range track {
+		if sig.Name != vss.FieldCurrentLocationLatitude {
+			continue
+		}
+		if !last.IsZero() && sig.Timestamp.Sub(last) >= time.Hour {
+			gaps++
+		}
+		last = sig.Timestamp
+	}
+	assert.Equal(t, 2, gaps)
+}
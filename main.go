@@ -3,8 +3,9 @@ package main
 import (
 	"cmp"
 	"errors"
-	"fmt"
+	"math"
 	"slices"
+	"sort"
 	"time"
 
 	"github.com/DIMO-Network/model-garage/pkg/vss"
@@ -16,8 +17,64 @@ const fieldCoordinates = "currentLocationCoordinates" // TODO(elffjs): Move this
 var zeroTime time.Time
 
 const (
-	maxLatLongDur   = 500 * time.Millisecond
-	pruneSignalName = "__drop"
+	maxLatLongDur    = 500 * time.Millisecond
+	defaultMaxFuture = time.Hour
+)
+
+// ErrNilInput is returned when the input slice is nil. An empty but
+// non-nil slice is fine, and gives an empty result. A nil slice is
+// more likely to come from a failed decode or a forgotten assignment.
+var ErrNilInput = errors.New("nil input slice")
+
+// Reasons passed to Config.OnDrop.
+const (
+	// ReasonOrigin means that the signal was half of a
+	// latitude-longitude pair at (0, 0).
+	ReasonOrigin = "origin"
+	// ReasonUnpaired means that the signal was a latitude or longitude
+	// without a partner.
+	ReasonUnpaired = "unpaired"
+	// ReasonOutOfRange means that the signal was half of a
+	// latitude-longitude pair with a coordinate out of range.
+	ReasonOutOfRange = "out of range"
+	// ReasonDuplicate means that the signal was an exact copy of
+	// another one.
+	ReasonDuplicate = "duplicate"
+	// ReasonFuture means that the signal's timestamp was too far in
+	// the future.
+	ReasonFuture = "future"
+	// ReasonHDOPMissing means that the signal was half of a
+	// latitude-longitude pair with no HDOP nearby, and
+	// Config.RequireHDOP was set.
+	ReasonHDOPMissing = "hdop missing"
+	// ReasonConsumed means that the signal was an HDOP that was folded
+	// into a created location, and Config.ConsumeHDOP was set.
+	ReasonConsumed = "consumed"
+	// ReasonSourceMismatch means that the signal was half of a
+	// latitude-longitude pair whose halves came from different sources,
+	// and Config.SourceConsistency was set.
+	ReasonSourceMismatch = "source mismatch"
+	// ReasonFrozenClock means that the signal was one of many
+	// coordinates with the same timestamp, and Config.DropFrozen was
+	// set.
+	ReasonFrozenClock = "frozen clock"
+	// ReasonMissingValue means that the signal was a latitude or
+	// longitude with no value set, according to Config.HasValue.
+	ReasonMissingValue = "missing value"
+	// ReasonInvalidHDOP means that the signal was an HDOP that was
+	// negative or not finite.
+	ReasonInvalidHDOP = "invalid hdop"
+	// ReasonInvalidSatellites means that the signal was a satellite
+	// count that was negative or not a whole number, and
+	// Config.SatellitesField was set.
+	ReasonInvalidSatellites = "invalid satellites"
+	// ReasonConflict means that the signal was a latitude or longitude
+	// that disagreed with another at the same timestamp, and was
+	// dropped under Config.ConflictResolution.
+	ReasonConflict = "conflict"
+	// ReasonFailFast means that the signal came after the first error,
+	// and Config.FailFast was set.
+	ReasonFailFast = "fail fast"
 )
 
 // ProcessSignals transforms a slice of input signals in ways that
@@ -27,185 +84,1281 @@ const (
 //     remove all but one.
 //   - Remove location values with latitude and longitude both equal
 //     to zero.
-//   - Roughly, for each triple of the input signals named
-//     currentLocationLatitude, currentLocationLongitude, and
-//     dimoAftermarketHDOP with sufficiently
-//     close timestamps, we will also emit a location-values signal
-//     named currentLocationCoordinates which combines all three.
+//   - Roughly, for each pair of the input signals named
+//     currentLocationLatitude and currentLocationLongitude with
+//     sufficiently close timestamps, we will also emit a
+//     location-values signal named currentLocationCoordinates (or
+//     Config.CoordinatesField) which combines the two with the
+//     dimoAftermarketHDOP (or another configured HDOP field) nearest
+//     in time, if one is close enough.
 //   - Remove unpaired latitudes and longitudes.
-//   - Remove values that are far into the future.
+//   - Remove values that are far into the future, by default more
+//     than an hour.
 //   - Remove coordinates at the origin (0, 0).
+//   - Remove HDOP values that are negative or not finite.
+//   - Remove latitudes outside [-90, 90] and longitudes outside
+//     [-180, 180], along with their partners.
+//   - Apply the origin and range checks to any incoming signals that
+//     already have the name currentLocationCoordinates, passing the
+//     valid ones through unchanged.
 //
 // The returned slice of signals is always meaningful, even if an error
 // is also returned.
 //
-// Note that this function may reorder the input slice.
-func ProcessSignals(signals []vss.Signal) ([]vss.Signal, error) {
-	store := newStore(signals)
+// A batch without any latitude, longitude, or combined location
+// signals, such as one of only HDOP, never yields a location. It comes
+// back as it went in, less duplicates, future signals, and invalid
+// HDOPs.
+//
+// Timestamps may be in any time zone, or a mix of them. They are
+// compared as instants, and are returned as given.
+//
+// The input slice is not modified, and the returned slice does not
+// share memory with it. Callers that can give up their input should
+// consider ProcessSignalsUnsafe, which avoids the copy.
+//
+// The default behavior can be adjusted by passing options; see
+// Config.
+func ProcessSignals(signals []vss.Signal, opts ...Option) ([]vss.Signal, error) {
+	return ProcessSignalsUnsafe(slices.Clone(signals), opts...)
+}
+
+// ProcessSignalsUnsafe is like ProcessSignals, but works in place. It
+// may reorder and overwrite the input slice, and the returned slice
+// may share its backing array. Don't use the input after calling this.
+func ProcessSignalsUnsafe(signals []vss.Signal, opts ...Option) ([]vss.Signal, error) {
+	store := newStore(signals, newConfig(opts))
+	out := store.ProcessAll()
+	return out, store.Err()
+}
+
+// New returns a Store that will process the given signals. A Store
+// can be reused for later batches by calling Reset. It returns an
+// error if the options produce a Config that fails Config.Validate.
+func New(signals []vss.Signal, opts ...Option) (*Store, error) {
+	cfg := newConfig(opts)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newStore(signals, cfg), nil
+}
+
+// MustNew is like New, but panics if the Config is invalid. It is for
+// options that are fixed in the code.
+func MustNew(signals []vss.Signal, opts ...Option) *Store {
+	store, err := New(signals, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// ProcessSignalsWithHDOP is like ProcessSignals, except that HDOP
+// values are taken from the separate slice hdops rather than from
+// positions. This is useful when HDOP is published on its own, slower
+// cadence. The HDOP signals are not included in the returned slice.
+// HDOP signals in positions pass through but are never attached, so a
+// nil or empty hdops means no HDOP at all. Neither input slice is
+// modified.
+func ProcessSignalsWithHDOP(positions, hdops []vss.Signal, opts ...Option) ([]vss.Signal, error) {
+	store := newStore(slices.Clone(positions), newConfig(opts))
+	store.hdopSignals = slices.Clone(hdops)
+	store.hdopInline = false
+	slices.SortFunc(store.hdopSignals, store.compare)
 	return store.processSignals()
 }
 
-func newStore(signals []vss.Signal) *coordinateStore {
-	return &coordinateStore{
-		signals:  signals,
-		lastLat:  -1,
-		lastLon:  -1,
-		lastHDOP: -1,
+func newStore(signals []vss.Signal, cfg Config) *Store {
+	c := &Store{
+		cfg:        cfg,
+		signals:    signals,
+		keyedHDOP:  -1,
+		hdopInline: true,
 	}
+	c.clearPair()
+	return c
 }
 
-type coordinateStore struct {
+// Store holds the state for processing a single batch of signals. Most
+// callers should use ProcessSignals instead; Store exists for services
+// that process a steady stream of batches and want to reuse its
+// buffers.
+type Store struct {
+	cfg Config
+
 	// lastLat is the index of the signals slice holding latitude for
 	// the location triple under construction. If there is no latitude
 	// yet found for the triple then the value of lastLat is -1.
 	lastLat int
 	// lastLon is like lastLat for longitude.
 	lastLon int
-	// lastHDOP is like lastLat for HDOP.
-	lastHDOP int
+	// lastStart is the index of the earliest signal in the active
+	// pair, or -1 if there is no active pair.
+	lastStart int
 	// lastTime is the timestamp of the earliest signal in the active
-	// triple. If we have no parts for the active triple then this
-	// will be the zero value of time.Time.
+	// pair. If we have no parts for the active pair then this will be
+	// the zero value of time.Time.
 	lastTime time.Time
 
+	// prepaired is parallel to signals, and marks the latitudes and
+	// longitudes that were paired ahead of the time-based pass, such
+	// as by cfg.CorrelationKey. It is empty until the first such pair
+	// is made.
+	prepaired []bool
+	// planned holds the pairs marked in prepaired. Their locations are
+	// created by the time-based pass when it reaches the earlier
+	// signal of each, so that cfg.FailFast cuts them off like any
+	// other.
+	planned []plannedPair
+	// keyedHDOP is the index of the HDOP to attach to the location
+	// under construction in place of the nearest one, or -1.
+	keyedHDOP int
+
+	// now is the current time, per cfg.Now, captured at the start of
+	// processing.
+	now time.Time
+
 	// signals is the input slice of signals.
 	signals []vss.Signal
+	// hdopSignals is the slice in which we look for HDOP. Usually this
+	// is just signals, but ProcessSignalsWithHDOP supplies a separate
+	// one.
+	hdopSignals []vss.Signal
+	// hdops holds the indices of the HDOP signals in hdopSignals, in
+	// sorted order. HDOP is not part of the pairing state machine;
+	// instead, each location looks here for the nearest HDOP on
+	// either side of it.
+	hdops []int
+	// hdopInline is true when hdopSignals is just signals, which is
+	// the case unless ProcessSignalsWithHDOP supplies its own.
+	hdopInline bool
+	// sats holds the indices of the valid satellite counts in signals,
+	// in sorted order, under cfg.SatellitesField.
+	sats []int
+	// hdopAttached is parallel to hdopSignals, and marks the HDOPs that
+	// were attached to a created location.
+	hdopAttached []bool
+	// orphanedHDOPs is the number of HDOP signals that were neither
+	// attached to a location nor dropped.
+	orphanedHDOPs int
+
+	// isDropped is parallel to signals, and marks the ones that will
+	// be removed from the output. Keeping this out of the signals
+	// themselves means that no real signal can be mistaken for a
+	// dropped one.
+	isDropped []bool
+	// dropped holds copies of the signals that we've marked for
+	// removal, in the order that we dropped them.
+	dropped []vss.Signal
+	// droppedReasons is parallel to dropped, and holds the reason for
+	// each.
+	droppedReasons []string
+	// droppedBy counts the dropped signals by reason.
+	droppedBy map[string]int
+
+	// hold, if true, leaves the pair still under construction at the
+	// end of the batch, and the HDOPs near the end, out of the output.
+	// They are copied to held instead, for a caller that will pass
+	// them in again with the next batch.
+	hold bool
+	// held holds the signals left out under hold.
+	held []vss.Signal
 
 	// created holds location signals that we've constructed while
 	// iterating over signals.
 	created []vss.Signal
+	// derived holds signals computed from the created locations, such
+	// as travelled distance.
+	derived []vss.Signal
+	// checkedNames records the signal names that have already been
+	// inspected for typos under cfg.StrictFields.
+	checkedNames map[string]struct{}
+	// lastCreated maps each TokenID to the value of the most recent
+	// location created for it. Only maintained when
+	// cfg.DropRepeatedFixes is set.
+	lastCreated map[uint32]vss.Location
+	// createdCount maps each TokenID to the number of locations created
+	// for it, plus one once it has hit the cap. Only maintained when
+	// cfg.MaxLocationsPerToken is set.
+	createdCount map[uint32]int
+	// lastKnown maps each TokenID to its most recent usable latitude
+	// and longitude. Only maintained when cfg.LastKnownWindow is set.
+	lastKnown map[uint32]knownCoordinates
 	// errs contains errors arising from location construction.
 	// Typically these have to do with unpaired coordinates, or
 	// latitude = longitude = 0.
 	errs []error
+	// errCount is the number of errors found, including any
+	// suppressed under cfg.MaxErrors.
+	errCount int
+	// err is the combined error from the last call to ProcessAll.
+	err error
+}
+
+// Process processes the store's signals, with the same semantics as
+// ProcessSignalsUnsafe. Call Reset before processing another batch.
+func (c *Store) Process() ([]vss.Signal, error) {
+	return c.processSignals()
+}
+
+// ProcessAll is like Process, but keeps the error for Err rather than
+// returning it.
+func (c *Store) ProcessAll() []vss.Signal {
+	var out []vss.Signal
+	out, c.err = c.processSignals()
+	return out
+}
+
+// Err returns the error from the last call to ProcessAll, or nil if
+// there was none.
+func (c *Store) Err() error {
+	return c.err
+}
+
+// Reset prepares the store to process a new batch of signals. Internal
+// buffers are truncated but keep their capacity, which is the point.
+// The configuration is unchanged.
+func (c *Store) Reset(signals []vss.Signal) {
+	c.next(signals)
+	clear(c.lastCreated)
+	clear(c.lastKnown)
+	clear(c.createdCount)
 }
 
-func (c *coordinateStore) processSignals() ([]vss.Signal, error) {
+// next is like Reset, but keeps what carries over from one batch of a
+// stream to the next: the last created and last known coordinates for
+// each TokenID, and the number of locations created for each.
+func (c *Store) next(signals []vss.Signal) {
+	c.clearPair()
+	c.prepaired = c.prepaired[:0]
+	c.planned = c.planned[:0]
+	c.keyedHDOP = -1
+
+	c.signals = signals
+	c.hdopSignals = nil
+	c.hdopInline = true
+	c.hdopAttached = c.hdopAttached[:0]
+	c.orphanedHDOPs = 0
+	c.hdops = c.hdops[:0]
+	c.sats = c.sats[:0]
+	c.isDropped = c.isDropped[:0]
+	c.dropped = c.dropped[:0]
+	c.droppedReasons = c.droppedReasons[:0]
+	clear(c.droppedBy)
+	c.held = c.held[:0]
+	c.created = c.created[:0]
+	c.derived = c.derived[:0]
+	clear(c.checkedNames)
+	c.errs = c.errs[:0]
+	c.errCount = 0
+	c.err = nil
+}
+
+func (c *Store) processSignals() ([]vss.Signal, error) {
+	if c.signals == nil {
+		return nil, ErrNilInput
+	}
 	if len(c.signals) == 0 {
 		return c.signals, nil
 	}
 
-	// Sorting this way makes it easier to handle time gaps. Sorting
-	// thereafter by name is not strictly necessary. Typically, this
-	// sorting will already have been performed upstream by a
-	// duplicate detector.
-	slices.SortFunc(c.signals, func(a, b vss.Signal) int {
-		return cmp.Or(a.Timestamp.Compare(b.Timestamp), cmp.Compare(a.Name, b.Name))
-	})
+	c.now = instant(c.cfg.Now())
 
-	for i := range c.signals {
-		c.processSignal(i)
+	// Many batches have no location data at all. For these we can skip
+	// the pairing machinery, but the sort, deduplication, and future
+	// check still apply.
+	assemble := c.cfg.StrictFields || c.hasLocationFields()
+
+	if c.cfg.CheckTimeOrder {
+		c.checkTimeOrder()
 	}
 
-	// One last attempt, in case we're in the process of constructing
-	// a location.
-	c.tryCreateLocation()
+	// Sorting this way makes it easier to handle time gaps, and puts
+	// duplicates next to each other.
+	c.sort(c.signals)
 
-	var out []vss.Signal
-	for _, sig := range c.signals {
-		if sig.Name != pruneSignalName {
-			out = append(out, sig)
+	c.isDropped = slices.Grow(c.isDropped[:0], len(c.signals))[:len(c.signals)]
+	clear(c.isDropped)
+
+	c.dropDuplicates()
+
+	if c.cfg.FrozenClockThreshold > 0 && c.cfg.OrderBy == nil {
+		c.checkFrozenClock()
+	}
+
+	if !c.cfg.IgnoreHDOP {
+		c.dropInvalidHDOPs()
+	}
+	if c.cfg.SatellitesField != "" {
+		c.indexSatellites()
+	}
+	if assemble && c.cfg.HasValue != nil {
+		c.dropMissingValues()
+	}
+
+	if assemble && !c.cfg.IgnoreHDOP {
+		c.indexHDOPs()
+	}
+
+	if assemble {
+		if c.cfg.CorrelationKey != nil {
+			c.pairByKey()
+		}
+		c.pairSimultaneous()
+		if c.cfg.NearestPairing {
+			c.pairNearest()
+		}
+	}
+
+	// Conflict warnings from deduplication don't count for
+	// cfg.FailFast.
+	errsBefore := c.errCount
+
+	slices.SortFunc(c.planned, func(a, b plannedPair) int { return cmp.Compare(a.start(), b.start()) })
+	next := 0
+
+	stop := len(c.signals)
+	if c.hold {
+		stop = c.holdPoint()
+	}
+
+	failed := false
+	for i := range c.signals[:stop] {
+		switch {
+		case next < len(c.planned) && c.planned[next].start() == i:
+			p := c.planned[next]
+			next++
+			// The active pair may end here, and under cfg.FailFast its
+			// errors stop the planned one from being created. A pair
+			// that reaches past stop is held instead.
+			c.closeAfterGap(c.signals[i])
+			if (!c.cfg.FailFast || c.errCount == errsBefore) && p.end() < stop {
+				c.createPlanned(p)
+			}
+		case c.isDropped[i] || len(c.prepaired) != 0 && c.prepaired[i]:
+			continue
+		case assemble:
+			c.processSignal(i)
+		default:
+			c.dropIfFuture(i)
+		}
+
+		if c.cfg.FailFast && c.errCount != errsBefore {
+			c.failAt(i, errsBefore)
+			failed = true
+			break
+		}
+	}
+
+	switch {
+	case failed:
+	case c.hold:
+		c.holdBack(stop)
+	case assemble:
+		// One last attempt, in case we're in the process of
+		// constructing a location.
+		c.tryCreateLocation()
+	}
+
+	// A pair that the time-based pass had open can be created after a
+	// later one paired in advance.
+	if len(c.planned) != 0 {
+		slices.SortStableFunc(c.created, c.compare)
+	}
+
+	if !c.cfg.IgnoreHDOP {
+		c.countOrphanedHDOPs(assemble)
+	}
+
+	if c.cfg.UnitSanityCheck {
+		if err := checkUnits(c.created); err != nil {
+			c.addErr(err)
+		}
+	}
+
+	if c.cfg.MinDistance > 0 {
+		c.created = thinByDistance(c.created, c.cfg.MinDistance, c.distance)
+	}
+
+	if c.cfg.MonotonicLocationTimestamps {
+		makeMonotonic(c.created)
+	}
+
+	if c.cfg.DistanceField != "" {
+		c.derived = travelledDistance(c.created, c.cfg.DistanceField, c.cfg.DistanceOffset, c.distance)
+	}
+
+	if c.cfg.SatellitesField != "" {
+		c.derived = append(c.derived, c.satelliteSignals()...)
+	}
+
+	if c.cfg.AccuracyUERE > 0 {
+		c.derived = append(c.derived, accuracySignals(c.created, c.cfg.AccuracyUERE)...)
+	}
+
+	if c.cfg.FixTTL > 0 {
+		c.derived = append(c.derived, expirySignals(c.created, c.cfg.FixTTL)...)
+	}
+
+	if c.cfg.GapMarkerThreshold > 0 {
+		c.derived = append(c.derived, gapMarkers(c.created, c.cfg.GapMarkerThreshold)...)
+	}
+
+	if c.cfg.SmoothingWindow > 0 {
+		c.derived = append(c.derived, smoothedLocations(c.created, c.cfg.SmoothingWindow)...)
+	}
+
+	if c.cfg.BoundingBoxSignal {
+		c.derived = append(c.derived, boundsSignals(c.created)...)
+	}
+
+	// Compact in place rather than copying the survivors into a new
+	// slice. The kept signals retain their sorted order, and the
+	// created locations and then any derived signals follow them,
+	// possibly reusing the tail of the input's backing array.
+	out := c.signals[:0]
+	if c.cfg.LocationsOnly {
+		c.derived = c.derived[:0]
+	} else {
+		for i, sig := range c.signals {
+			if !c.isDropped[i] {
+				out = append(out, sig)
+			}
 		}
 	}
 
 	out = append(out, c.created...)
+	out = append(out, c.derived...)
+
+	if suppressed := c.errCount - len(c.errs); suppressed > 0 {
+		c.errs = append(c.errs, &SuppressedError{Count: suppressed})
+	}
+	if c.cfg.AggregateErrors {
+		c.errs = aggregateErrors(c.errs)
+	}
 
 	return out, errors.Join(c.errs...)
 }
 
-func (c *coordinateStore) processSignal(index int) {
-	sig := c.signals[index]
-
-	if !c.lastTime.IsZero() && sig.Timestamp.Sub(c.lastTime) >= maxLatLongDur {
+// closeAfterGap finishes the active pair, if any, when sig is too far
+// from it to join it.
+func (c *Store) closeAfterGap(sig vss.Signal) {
+	if c.lastStart != -1 && (c.separate(c.signals[c.lastStart], sig) || c.span(c.signals[c.lastStart], sig) >= c.pairWindow()) {
+		if c.cfg.Trace != nil {
+			c.tracef("closing pair started at %s: gap to %s at %s", traceTime(c.lastTime), sig.Name, traceTime(sig.Timestamp))
+		}
 		c.tryCreateLocation()
 	}
+}
+
+// holdPoint returns the index at which the time-based pass stops under
+// hold. A location created before it can't have an HDOP in the next
+// batch within its reach, nor a signal that arrives late within
+// cfg.ReorderWindow.
+func (c *Store) holdPoint() int {
+	tail := c.reorderWindow()
+	if !c.cfg.IgnoreHDOP {
+		tail = max(tail, c.hdopWindow())
+	}
+	if tail == 0 {
+		return len(c.signals)
+	}
+	last := c.signals[len(c.signals)-1]
+	return sort.Search(len(c.signals), func(i int) bool {
+		return !c.separate(c.signals[i], last) && c.span(c.signals[i], last) < tail
+	})
+}
+
+// reorderWindow returns cfg.ReorderWindow, or zero under cfg.OrderBy.
+func (c *Store) reorderWindow() int64 {
+	if c.cfg.OrderBy != nil {
+		return 0
+	}
+	return int64(c.cfg.ReorderWindow)
+}
+
+// holdBack moves the signals that the next batch may still combine with
+// to held, given the index at which the time-based pass stopped: those
+// from there on, the latitude and longitude of the active pair, if the
+// first of them doesn't finish it, and of any planned pair that
+// reaches past stop, and the HDOPs within the HDOP window before the
+// earliest of them. They're marked as dropped so that they stay out of
+// the output, but they're not reported as such.
+func (c *Store) holdBack(stop int) {
+	// The first signal past stop that would have been processed may
+	// show that the active pair is finished after all. A late arrival
+	// might still come before it, though.
+	for i := stop; i < len(c.signals) && c.reorderWindow() == 0; i++ {
+		if c.isDropped[i] || checkFuture(c.signals[i], c.cfg.MaxFuture, c.now) != nil {
+			continue
+		}
+		if c.lastStart != -1 {
+			c.closeAfterGap(c.signals[i])
+		}
+		break
+	}
+
+	pairs := make(map[int]bool)
+	from := stop
+	if c.lastStart != -1 {
+		pairs[c.lastLat] = c.lastLat != -1
+		pairs[c.lastLon] = c.lastLon != -1
+		from = min(from, c.lastStart)
+	}
+	for _, p := range c.planned {
+		if p.start() < stop && p.end() >= stop {
+			pairs[p.lat], pairs[p.lon] = true, true
+			from = min(from, p.start())
+		}
+	}
+
+	for i, sig := range c.signals {
+		if c.isDropped[i] {
+			continue
+		}
+		hdop := from < len(c.signals) && !c.cfg.IgnoreHDOP && c.isHDOP(sig.Name) &&
+			c.span(sig, c.signals[from]) < c.hdopWindow()
+		if i >= stop || pairs[i] || hdop {
+			c.isDropped[i] = true
+			c.held = append(c.held, sig)
+		}
+	}
+	c.clearPair()
+}
+
+// failAt cuts the batch off at the given index under cfg.FailFast.
+// Everything before it has been settled. The signal at the index has
+// either been dropped or is part of an unfinished pair, so it goes with
+// the rest. Those not already dropped are dropped with ReasonFailFast.
+// The first n errors came from the passes before pairing, and those
+// timed at or after the cut go too.
+func (c *Store) failAt(index, n int) {
+	for i := index; i < len(c.signals); i++ {
+		if !c.isDropped[i] {
+			c.drop(i, ReasonFailFast)
+		}
+	}
+
+	cut := instant(c.signals[index].Timestamp)
+	c.signals = c.signals[:index]
+
+	n = min(n, len(c.errs))
+	kept := c.errs[:0]
+	for k, err := range c.errs {
+		if ce, ok := err.(categorizedError); ok && k < n && !instant(ce.at()).Before(cut) {
+			c.errCount--
+			continue
+		}
+		kept = append(kept, err)
+	}
+	clear(c.errs[len(kept):])
+	c.errs = kept
+}
+
+func (c *Store) processSignal(index int) {
+	if c.dropIfFuture(index) {
+		return
+	}
+
+	sig := c.signals[index]
+
+	c.closeAfterGap(sig)
 
 	// This logic could be made shorter and less repetitive by
 	// playing around with *int.
 	switch sig.Name {
 	case vss.FieldCurrentLocationLatitude:
 		if c.lastLat != -1 {
-			// Start a new triple, but see if what's already being
+			// Start a new pair, but see if what's already being
 			// tracked is enough to yield a row.
+			if c.cfg.Trace != nil {
+				c.tracef("closing pair started at %s: second latitude at %s", traceTime(c.lastTime), traceTime(sig.Timestamp))
+			}
 			c.tryCreateLocation()
 		}
 		c.lastLat = index
 	case vss.FieldCurrentLocationLongitude:
 		if c.lastLon != -1 {
+			if c.cfg.Trace != nil {
+				c.tracef("closing pair started at %s: second longitude at %s", traceTime(c.lastTime), traceTime(sig.Timestamp))
+			}
 			c.tryCreateLocation()
 		}
+		if c.cfg.WrapLongitude {
+			c.signals[index].ValueNumber = wrapLongitude(sig.ValueNumber)
+		}
 		c.lastLon = index
-	case vss.FieldDIMOAftermarketHDOP:
-		if c.lastHDOP != -1 {
-			c.tryCreateLocation()
+	case c.cfg.CoordinatesField:
+		// Some sources send coordinates already combined. These go
+		// through the same checks, but there's nothing to pair.
+		if err := checkCoordinates(sig.TokenID, sig.Timestamp, sig.ValueLocation); err != nil {
+			c.drop(index, err.category())
+			c.addErr(err)
 		}
-		c.lastHDOP = index
+		return
 	default:
+		if c.cfg.StrictFields {
+			c.checkFieldName(sig.Name)
+		}
 		return
 	}
 
-	if c.lastTime.IsZero() {
+	if c.lastStart == -1 {
+		c.lastStart = index
 		c.lastTime = sig.Timestamp
+		if c.cfg.Trace != nil {
+			c.tracef("started pair at %s with %s", traceTime(sig.Timestamp), sig.Name)
+		}
+	}
+}
+
+// indexHDOPs fills in hdops, taking hdopSignals to be signals unless
+// it was supplied separately.
+func (c *Store) indexHDOPs() {
+	defer func() {
+		c.hdopAttached = slices.Grow(c.hdopAttached[:0], len(c.hdopSignals))[:len(c.hdopSignals)]
+		clear(c.hdopAttached)
+	}()
+
+	if c.hdopInline {
+		for i, sig := range c.signals {
+			if c.isHDOP(sig.Name) && !c.isDropped[i] {
+				c.hdops = append(c.hdops, i)
+			}
+		}
+		c.hdopSignals = c.signals
+		return
+	}
+
+	for i, sig := range c.hdopSignals {
+		if c.isHDOP(sig.Name) {
+			c.hdops = append(c.hdops, i)
+		}
+	}
+}
+
+// countOrphanedHDOPs sets orphanedHDOPs. If assemble is false then no
+// locations were created, and every HDOP that wasn't dropped counts.
+func (c *Store) countOrphanedHDOPs(assemble bool) {
+	if !assemble {
+		for i, sig := range c.signals {
+			if c.isHDOP(sig.Name) && !c.isDropped[i] {
+				c.orphanedHDOPs++
+			}
+		}
+		return
 	}
+
+	for _, i := range c.hdops {
+		if !c.hdopAttached[i] && !(c.hdopInline && c.isDropped[i]) {
+			c.orphanedHDOPs++
+		}
+	}
+}
+
+// dropIfFuture drops the signal at the given index if its timestamp
+// is too far in the future, and reports whether it did so.
+func (c *Store) dropIfFuture(index int) bool {
+	err := checkFuture(c.signals[index], c.cfg.MaxFuture, c.now)
+	if err == nil {
+		return false
+	}
+	c.drop(index, ReasonFuture)
+	c.addErr(err)
+	return true
+}
+
+// hasLocationFields reports whether any of the signals could take part
+// in a location: a latitude, a longitude, or an already combined
+// location. HDOP alone can't produce anything.
+func (c *Store) hasLocationFields() bool {
+	return slices.ContainsFunc(c.signals, func(sig vss.Signal) bool {
+		switch sig.Name {
+		case vss.FieldCurrentLocationLatitude, vss.FieldCurrentLocationLongitude, c.cfg.CoordinatesField:
+			return true
+		}
+		return false
+	})
+}
+
+// compare orders signals for processing: by timestamp and then by
+// name, unless cfg.OrderBy is set, in which case that goes first.
+// cfg.SortLess overrides both.
+func (c *Store) compare(a, b vss.Signal) int {
+	if c.cfg.SortLess != nil {
+		return c.cfg.SortLess(a, b)
+	}
+	if c.cfg.OrderBy != nil {
+		return cmp.Or(cmp.Compare(c.cfg.OrderBy(a), c.cfg.OrderBy(b)), instant(a.Timestamp).Compare(instant(b.Timestamp)), cmp.Compare(a.Name, b.Name))
+	}
+	return cmp.Or(instant(a.Timestamp).Compare(instant(b.Timestamp)), cmp.Compare(a.Name, b.Name))
+}
+
+// sort sorts signals with compare. The sort is stable, so that signals
+// with the same timestamp and name keep their input order. This decides
+// which of a set of duplicates under cfg.DuplicateKey is kept, and how
+// simultaneous coordinates pair up.
+func (c *Store) sort(signals []vss.Signal) {
+	slices.SortStableFunc(signals, c.compare)
+}
+
+// search returns the position in indices, which point into signals in
+// processing order, of the first signal at or after target.
+func (c *Store) search(signals []vss.Signal, indices []int, target vss.Signal) int {
+	i, _ := slices.BinarySearchFunc(indices, target, func(index int, target vss.Signal) int {
+		// Under cfg.SortLess, time only increases within a TokenID.
+		if c.cfg.SortLess != nil {
+			return c.compare(signals[index], target)
+		}
+		return cmp.Compare(c.span(target, signals[index]), 0)
+	})
+	return i
+}
+
+// separate reports whether a and b may not be combined however close
+// they are in time, which under cfg.SortLess is when their TokenIDs
+// differ.
+func (c *Store) separate(a, b vss.Signal) bool {
+	return c.cfg.SortLess != nil && a.TokenID != b.TokenID
+}
+
+// span returns how far b comes after a. This is in nanoseconds, or in
+// sequence units if cfg.OrderBy is set.
+func (c *Store) span(a, b vss.Signal) int64 {
+	if c.cfg.OrderBy != nil {
+		return c.cfg.OrderBy(b) - c.cfg.OrderBy(a)
+	}
+	return int64(instant(b.Timestamp).Sub(instant(a.Timestamp)))
+}
+
+// window returns the span within which signals may be combined into
+// one location. Signals that are exactly this far apart may not.
+func (c *Store) window() int64 {
+	if c.cfg.OrderBy != nil {
+		return c.cfg.SeqGap
+	}
+	return int64(c.cfg.LocationGap)
+}
+
+// pairWindow returns the span within which a latitude and longitude
+// may be paired with each other. This is the same as window, unless
+// cfg.SimultaneityWindow narrows it.
+func (c *Store) pairWindow() int64 {
+	if c.cfg.OrderBy == nil && c.cfg.SimultaneityWindow > 0 {
+		return min(int64(c.cfg.SimultaneityWindow), c.window())
+	}
+	return c.window()
+}
+
+// hdopWindow returns the span within which an HDOP may be attached to
+// a location. This is the same as window, unless cfg.HDOPGap widens or
+// narrows it.
+func (c *Store) hdopWindow() int64 {
+	if c.cfg.OrderBy == nil && c.cfg.HDOPGap > 0 {
+		return int64(c.cfg.HDOPGap)
+	}
+	return c.window()
+}
+
+// isHDOP reports whether name is one of the configured HDOP fields.
+func (c *Store) isHDOP(name string) bool {
+	return slices.Contains(c.cfg.HDOPFields, name)
+}
+
+// nearestHDOP returns the index in hdopSignals of the HDOP signal
+// closest to the given one, provided that it is within hdopWindow.
+// If there is no such signal then it returns -1. Under
+// cfg.HDOPPriority, only the signals with the most preferred name
+// present in the window are considered.
+func (c *Store) nearestHDOP(target vss.Signal) int {
+	if !c.cfg.HDOPPriority {
+		return c.nearestHDOPNamed(target, "")
+	}
+	for _, name := range c.cfg.HDOPFields {
+		if hdop := c.nearestHDOPNamed(target, name); hdop != -1 {
+			return hdop
+		}
+	}
+	return -1
+}
+
+// nearestHDOPNamed is like nearestHDOP, but only considers the HDOP
+// signals with the given name, or all of them if name is empty. Ties
+// are settled according to cfg.HDOPTieBreak.
+func (c *Store) nearestHDOPNamed(target vss.Signal, name string) int {
+	before, after := c.bracketingHDOPs(target, name)
+	if before == -1 {
+		return after
+	}
+	if after == -1 {
+		return before
+	}
+
+	switch dBefore, dAfter := c.span(c.hdopSignals[before], target), c.span(target, c.hdopSignals[after]); {
+	case dBefore < dAfter:
+		return before
+	case dAfter < dBefore:
+		return after
+	}
+
+	switch c.cfg.HDOPTieBreak {
+	case TieBreakLater:
+		return after
+	case TieBreakLower:
+		if c.hdopSignals[after].ValueNumber < c.hdopSignals[before].ValueNumber {
+			return after
+		}
+	}
+	return before
+}
+
+// bracketingHDOPs returns the indices in hdopSignals of the last HDOP
+// signal strictly before the given one and the first at or after it,
+// among those with the given name, or all of them if name is empty.
+// Either is -1 if there is no such signal within hdopWindow.
+func (c *Store) bracketingHDOPs(target vss.Signal, name string) (before, after int) {
+	// i is the position of the first HDOP at or after target.
+	i := c.search(c.hdopSignals, c.hdops, target)
+
+	before, after = -1, -1
+	for j := i - 1; j >= 0 && !c.separate(c.hdopSignals[c.hdops[j]], target) && c.span(c.hdopSignals[c.hdops[j]], target) < c.hdopWindow(); j-- {
+		if name == "" || c.hdopSignals[c.hdops[j]].Name == name {
+			before = c.hdops[j]
+			break
+		}
+	}
+	for j := i; j < len(c.hdops) && !c.separate(target, c.hdopSignals[c.hdops[j]]) && c.span(target, c.hdopSignals[c.hdops[j]]) < c.hdopWindow(); j++ {
+		if name == "" || c.hdopSignals[c.hdops[j]].Name == name {
+			after = c.hdops[j]
+			break
+		}
+	}
+	return before, after
+}
+
+// interpolatedHDOP returns the HDOP for the given signal, linearly
+// interpolated between the HDOP signals with the given name, or any
+// name if it is empty, on either side of it. If only one side has an
+// HDOP in the window then that one is used, and if neither does then
+// ok is false.
+func (c *Store) interpolatedHDOP(target vss.Signal, name string) (hdop float64, ok bool) {
+	before, after := c.bracketingHDOPs(target, name)
+	switch {
+	case before == -1 && after == -1:
+		return 0, false
+	case before == -1:
+		return c.hdopSignals[after].ValueNumber, true
+	case after == -1:
+		return c.hdopSignals[before].ValueNumber, true
+	}
+
+	b, a := c.hdopSignals[before], c.hdopSignals[after]
+	frac := float64(c.span(b, target)) / float64(c.span(b, a))
+	return b.ValueNumber + frac*(a.ValueNumber-b.ValueNumber), true
 }
 
 // tryCreateLocation tries to add a VSS location row using the active
-// location triple.
+// location pair.
 //
 // Only call this function when forced: if there is any chance that
-// the triple can be completed by the next element of the slice then
-// calling this function may discard the elements of the active triple
+// the pair can be completed by the next element of the slice then
+// calling this function may discard the elements of the active pair
 // on the grounds of being incomplete.
-func (c *coordinateStore) tryCreateLocation() {
+//
+// Whatever happens, the pair is cleared afterward.
+func (c *Store) tryCreateLocation() {
+	defer c.clearPair()
+
+	// The location is built only from ValueNumber. Latitude and
+	// longitude signals sometimes carry a stale ValueLocation, and
+	// none of it may leak into the result.
 	var loc vss.Location
-	var create bool
+	var create, lastKnown bool
 
 	template := c.signals[0]
+	if c.cfg.SortLess != nil && c.lastStart != -1 {
+		// The batch may hold several TokenIDs, each kept together.
+		template.TokenID = c.signals[c.lastStart].TokenID
+	}
 
-	if c.lastLat != -1 && c.lastLon != -1 {
-		lat := c.signals[c.lastLat].ValueNumber
-		lon := c.signals[c.lastLon].ValueNumber
+	if c.lastLat != -1 && c.lastLon != -1 && c.cfg.SourceConsistency && c.signals[c.lastLat].Source != c.signals[c.lastLon].Source {
+		c.addErr(&SourceMismatchError{
+			TokenID:         c.signals[c.lastLat].TokenID,
+			Time:            c.lastTime,
+			LatitudeSource:  c.signals[c.lastLat].Source,
+			LongitudeSource: c.signals[c.lastLon].Source,
+		})
+		c.dropPair(ReasonSourceMismatch)
+	} else if c.lastLat != -1 && c.lastLon != -1 {
+		loc.Latitude = c.signals[c.lastLat].ValueNumber
+		loc.Longitude = c.signals[c.lastLon].ValueNumber
+		loc = c.round(c.reproject(loc))
 
-		if lat == 0 && lon == 0 {
-			c.signals[c.lastLat].Name = pruneSignalName
-			c.signals[c.lastLon].Name = pruneSignalName
-			c.errs = append(c.errs, fmt.Errorf("latitude and longitude at origin at time %s", fmtTime(c.lastTime)))
+		if err := checkCoordinates(c.signals[c.lastLat].TokenID, c.lastTime, loc); err != nil {
+			c.dropPair(err.category())
+			c.addErr(err)
 		} else {
-			loc.Latitude = lat
-			loc.Longitude = lon
 			create = true
 		}
+	} else if loc, lastKnown = c.lastKnownLocation(); lastKnown {
+		create = true
 	} else if c.lastLat != -1 {
-		c.signals[c.lastLat].Name = pruneSignalName
-		c.errs = append(c.errs, fmt.Errorf("unpaired latitude at time %s", fmtTime(c.lastTime)))
+		if c.cfg.Trace != nil {
+			c.tracef("dropped unpaired latitude at %s", traceTime(c.lastTime))
+		}
+		c.drop(c.lastLat, ReasonUnpaired)
+		c.addErr(&UnpairedError{TokenID: c.signals[c.lastLat].TokenID, Name: vss.FieldCurrentLocationLatitude, Time: c.lastTime})
 	} else if c.lastLon != -1 {
-		c.signals[c.lastLon].Name = pruneSignalName
-		c.errs = append(c.errs, fmt.Errorf("unpaired longitude at time %s", fmtTime(c.lastTime)))
+		if c.cfg.Trace != nil {
+			c.tracef("dropped unpaired longitude at %s", traceTime(c.lastTime))
+		}
+		c.drop(c.lastLon, ReasonUnpaired)
+		c.addErr(&UnpairedError{TokenID: c.signals[c.lastLon].TokenID, Name: vss.FieldCurrentLocationLongitude, Time: c.lastTime})
 	}
 
-	if c.lastHDOP != -1 {
-		loc.HDOP = c.signals[c.lastHDOP].ValueNumber
-		create = true
+	hdop := -1
+	if create && !c.cfg.IgnoreHDOP {
+		hdop = c.keyedHDOP
+		if hdop == -1 {
+			hdop = c.nearestHDOP(c.signals[c.lastStart])
+		}
+		if hdop != -1 {
+			if err := checkHDOP(c.hdopSignals[hdop]); err != nil {
+				c.addErr(err)
+				hdop = -1
+			}
+		}
+		if hdop != -1 {
+			loc.HDOP = c.hdopSignals[hdop].ValueNumber
+			if c.cfg.InterpolateHDOP && c.keyedHDOP == -1 {
+				name := ""
+				if c.cfg.HDOPPriority {
+					name = c.hdopSignals[hdop].Name
+				}
+				loc.HDOP, _ = c.interpolatedHDOP(c.signals[c.lastStart], name)
+			}
+
+			if c.cfg.MaxHDOP > 0 && loc.HDOP > c.cfg.MaxHDOP {
+				c.addErr(&PoorFixError{TokenID: template.TokenID, Time: c.lastTime, HDOP: loc.HDOP, MaxHDOP: c.cfg.MaxHDOP})
+				if c.cfg.Trace != nil {
+					c.tracef("not creating location for pair started at %s: HDOP %g above maximum", traceTime(c.lastTime), loc.HDOP)
+				}
+				create = false
+			}
+		} else if c.cfg.RequireHDOP {
+			c.dropPair(ReasonHDOPMissing)
+			c.addErr(&HDOPMissingError{TokenID: c.signals[c.lastStart].TokenID, Time: c.lastTime})
+			create = false
+		}
+	}
+
+	if create && c.cfg.BoundingBox != nil && !c.cfg.BoundingBox.Contains(loc.Latitude, loc.Longitude) {
+		c.addErr(&OutOfRegionError{TokenID: template.TokenID, Time: c.lastTime, Latitude: loc.Latitude, Longitude: loc.Longitude})
+		if c.cfg.Trace != nil {
+			c.tracef("not creating location for pair started at %s: outside bounding box", traceTime(c.lastTime))
+		}
+		create = false
+	}
+
+	if c.cfg.LastKnownWindow > 0 {
+		c.rememberCoordinates()
 	}
 
 	if create {
-		c.created = append(c.created, vss.Signal{
-			TokenID:       template.TokenID,
-			Timestamp:     c.lastTime,
-			Name:          fieldCoordinates,
-			ValueLocation: loc,
-			Source:        template.Source,
-			Producer:      template.Producer,
-			CloudEventID:  template.CloudEventID,
-		})
+		// Start from a full copy of the template, so that any fields
+		// that vss.Signal gains later are carried over too.
+		sig := template
+		sig.Timestamp = c.locationTime()
+		sig.Name = c.cfg.CoordinatesField
+		sig.ValueNumber = 0
+		sig.ValueString = ""
+		sig.ValueLocation = loc
+		if c.cfg.DefaultSource != "" {
+			sig.Source = c.cfg.DefaultSource
+		}
+		if c.cfg.DefaultProducer != "" {
+			sig.Producer = c.cfg.DefaultProducer
+		}
+
+		if c.cfg.Confidence != nil && c.cfg.SetConfidence != nil {
+			sig = c.setConfidence(sig, hdop)
+		}
+
+		if lastKnown && c.cfg.MarkLastKnown != nil {
+			sig = c.markLastKnown(sig)
+		}
+
+		if c.cfg.SetSampleCount != nil {
+			n := 2
+			if hdop != -1 {
+				n++
+			}
+			sig = c.setSampleCount(sig, n)
+		}
+
+		if c.cfg.SetValueType != nil {
+			sig = c.setValueType(sig)
+		}
+
+		if c.cfg.Trace != nil {
+			c.tracef("creating location at %s from pair started at %s", traceTime(sig.Timestamp), traceTime(c.lastTime))
+		}
+		if c.create(sig) && hdop != -1 {
+			c.hdopAttached[hdop] = true
+			// A separately supplied HDOP is never in the output anyway.
+			if c.cfg.ConsumeHDOP && c.hdopInline && !c.isDropped[hdop] {
+				c.drop(hdop, ReasonConsumed)
+			}
+		}
 	}
 
+}
+
+// clearPair forgets the pair under construction. This is the only
+// place that the pair state is reset, so that no path out of
+// tryCreateLocation can leave a stale index behind for the next pair.
+func (c *Store) clearPair() {
 	c.lastLat = -1
 	c.lastLon = -1
-	c.lastHDOP = -1
+	c.lastStart = -1
 	c.lastTime = zeroTime
 }
 
+// locationTime returns the timestamp for a location created from the
+// active pair, according to cfg.LocationTimestamp. If the pair is
+// missing the requested member then the earliest time is used.
+func (c *Store) locationTime() time.Time {
+	switch c.cfg.LocationTimestamp {
+	case TimestampLatitude:
+		if c.lastLat != -1 {
+			return c.signals[c.lastLat].Timestamp
+		}
+	case TimestampLongitude:
+		if c.lastLon != -1 {
+			return c.signals[c.lastLon].Timestamp
+		}
+	case TimestampLatest:
+		if c.lastLat != -1 && c.lastLon != -1 && c.compare(c.signals[c.lastLat], c.signals[c.lastLon]) < 0 {
+			return c.signals[c.lastLon].Timestamp
+		}
+		if c.lastLat != -1 {
+			return c.signals[c.lastLat].Timestamp
+		}
+		return c.signals[c.lastLon].Timestamp
+	}
+	return c.lastTime
+}
+
+// round applies cfg.CoordinatePrecision and cfg.Antimeridian to loc.
+// This comes before the checks on the coordinates, since rounding can
+// land on the origin or outside the bounding box.
+func (c *Store) round(loc vss.Location) vss.Location {
+	if c.cfg.CoordinatePrecision > 0 {
+		loc.Latitude = roundHalfEven(loc.Latitude, c.cfg.CoordinatePrecision)
+		loc.Longitude = roundHalfEven(loc.Longitude, c.cfg.CoordinatePrecision)
+	}
+	// Rounding can land on the antimeridian, so this goes after it.
+	if c.cfg.Antimeridian == AntimeridianWest && loc.Longitude == 180 {
+		loc.Longitude = -180
+	}
+	return loc
+}
+
+// checkCoordinates returns an error if the given location is at the
+// origin or out of range. The TokenID and time are only used to fill
+// in the error.
+func checkCoordinates(tokenID uint32, t time.Time, loc vss.Location) categorizedError {
+	lat, lon := loc.Latitude, loc.Longitude
+	if lat == 0 && lon == 0 {
+		return &OriginError{TokenID: tokenID, Time: t}
+	}
+	// Written this way to catch NaN.
+	if !(-90 <= lat && lat <= 90) || !(-180 <= lon && lon <= 180) {
+		return &OutOfRangeError{TokenID: tokenID, Time: t, Latitude: lat, Longitude: lon}
+	}
+	return nil
+}
+
+// setConfidence gives sig, a location built from the active pair and
+// the given HDOP, the lowest confidence of those inputs. This is
+// split out because taking the address of sig makes it escape.
+func (c *Store) setConfidence(sig vss.Signal, hdop int) (out vss.Signal) {
+	out = sig
+	defer c.recoverCallback("Confidence")
+
+	conf := math.Inf(1)
+	// Under cfg.LastKnownWindow, one of these may be missing.
+	for _, i := range []int{c.lastLat, c.lastLon} {
+		if i != -1 {
+			conf = min(conf, c.cfg.Confidence(c.signals[i]))
+		}
+	}
+	if hdop != -1 {
+		conf = min(conf, c.cfg.Confidence(c.hdopSignals[hdop]))
+	}
+	c.cfg.SetConfidence(&out, conf)
+	return out
+}
+
+// markLastKnown flags sig as built with a last-known coordinate. Like
+// setConfidence, this is split out to keep sig on the stack.
+func (c *Store) markLastKnown(sig vss.Signal) (out vss.Signal) {
+	out = sig
+	defer c.recoverCallback("MarkLastKnown")
+
+	c.cfg.MarkLastKnown(&out)
+	return out
+}
+
+// setSampleCount records on sig the number of signals it was built
+// from. Like setConfidence, this is split out to keep sig on the
+// stack.
+func (c *Store) setSampleCount(sig vss.Signal, n int) (out vss.Signal) {
+	out = sig
+	defer c.recoverCallback("SetSampleCount")
+
+	c.cfg.SetSampleCount(&out, n)
+	return out
+}
+
+// setValueType returns a copy of sig with cfg.SetValueType applied.
+func (c *Store) setValueType(sig vss.Signal) (out vss.Signal) {
+	out = sig
+	defer c.recoverCallback("SetValueType")
+
+	c.cfg.SetValueType(&out)
+	return out
+}
+
+// dropPair drops whichever members of the active pair are present.
+func (c *Store) dropPair(reason string) {
+	if c.cfg.Trace != nil {
+		c.tracef("dropped pair started at %s: %s", traceTime(c.lastTime), reason)
+	}
+	if c.lastLat != -1 {
+		c.drop(c.lastLat, reason)
+	}
+	if c.lastLon != -1 {
+		c.drop(c.lastLon, reason)
+	}
+}
+
+// drop marks the signal at the given index for removal from the
+// output, and records a copy of it in dropped.
+func (c *Store) drop(index int, reason string) {
+	sig := c.signals[index]
+	c.isDropped[index] = true
+	c.dropped = append(c.dropped, sig)
+	c.droppedReasons = append(c.droppedReasons, reason)
+	if c.droppedBy == nil {
+		c.droppedBy = make(map[string]int)
+	}
+	c.droppedBy[reason]++
+
+	if c.cfg.OnDrop != nil {
+		c.onDrop(sig, reason)
+	}
+}
+
+// create adds a newly constructed location signal to the output, and
+// reports whether it did so. It may not, for example if the location
+// repeats the last one.
+func (c *Store) create(loc vss.Signal) bool {
+	if c.cfg.DropRepeatedFixes {
+		if c.isRepeat(loc) {
+			return false
+		}
+		if c.lastCreated == nil {
+			c.lastCreated = make(map[uint32]vss.Location)
+		}
+		c.lastCreated[loc.TokenID] = loc.ValueLocation
+	}
+
+	if c.cfg.MaxLocationsPerToken > 0 {
+		if c.createdCount == nil {
+			c.createdCount = make(map[uint32]int)
+		}
+		n := c.createdCount[loc.TokenID]
+		if n >= c.cfg.MaxLocationsPerToken {
+			if n == c.cfg.MaxLocationsPerToken {
+				// Only complain the first time.
+				c.addErr(&CapExceededError{TokenID: loc.TokenID, Time: loc.Timestamp, Max: c.cfg.MaxLocationsPerToken})
+				c.createdCount[loc.TokenID] = n + 1
+			}
+			return false
+		}
+		c.createdCount[loc.TokenID] = n + 1
+	}
+
+	c.created = append(c.created, loc)
+
+	if c.cfg.OnCreate != nil {
+		c.onCreate(loc)
+	}
+
+	return true
+}
+
+// isRepeat reports whether loc has the same position as the last
+// location created for its TokenID.
+func (c *Store) isRepeat(loc vss.Signal) bool {
+	prev, ok := c.lastCreated[loc.TokenID]
+	if !ok {
+		return false
+	}
+	cur := loc.ValueLocation
+	if prev.Latitude != cur.Latitude || prev.Longitude != cur.Longitude {
+		return false
+	}
+	return !c.cfg.RepeatedFixesCompareHDOP || prev.HDOP == cur.HDOP
+}
+
+// makeMonotonic moves the timestamp of each location that is not
+// after the previous one for its TokenID to a nanosecond after that.
+func makeMonotonic(locs []vss.Signal) {
+	last := make(map[uint32]time.Time)
+	for i, loc := range locs {
+		prev, ok := last[loc.TokenID]
+		if ok && !instant(loc.Timestamp).After(prev) {
+			locs[i].Timestamp = prev.Add(time.Nanosecond)
+		}
+		last[loc.TokenID] = instant(locs[i].Timestamp)
+	}
+}
+
+// instant strips any monotonic clock reading from t, so that it is
+// compared purely as a point on the UTC timeline. When two times both
+// carry monotonic readings, Go compares those instead, and they can
+// disagree with the wall clock. The time zone never matters.
+func instant(t time.Time) time.Time {
+	return t.Round(0)
+}
+
+// addErr records an error, unless cfg.MaxErrors have already been
+// recorded, in which case it is only counted.
+func (c *Store) addErr(err error) {
+	c.errCount++
+	if c.cfg.MaxErrors > 0 && len(c.errs) >= c.cfg.MaxErrors {
+		return
+	}
+	c.errs = append(c.errs, err)
+}
+
+// checkTimeOrder returns a BackwardsTimeWarning for each signal, in
+// input order, whose timestamp is earlier than that of the previous
+// signal with the same TokenID.
+func (c *Store) checkTimeOrder() {
+	last := make(map[uint32]time.Time)
+	for _, sig := range c.signals {
+		t := instant(sig.Timestamp)
+		if prev, ok := last[sig.TokenID]; ok && t.Before(prev) {
+			c.addErr(&BackwardsTimeWarning{TokenID: sig.TokenID, Name: sig.Name, Time: sig.Timestamp, Previous: prev})
+			continue
+		}
+		last[sig.TokenID] = t
+	}
+}
+
 // fmtTime formats the given time per RFC-3339, for use in errors
 // returned to the client. The default Go format used for %s is not
 // standard.
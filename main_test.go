@@ -1,10 +1,18 @@
 package main
 
 import (
+	"cmp"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/elffjs/locgen/locgentest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,6 +25,13 @@ func TestEmptyInput(t *testing.T) {
 	assert.Empty(t, actual)
 }
 
+func TestNilInput(t *testing.T) {
+	actual, err := ProcessSignals(nil)
+
+	assert.ErrorIs(t, err, ErrNilInput)
+	assert.Nil(t, actual)
+}
+
 func TestNonLocationSignalUntouched(t *testing.T) {
 	now := time.Now()
 
@@ -126,3 +141,1324 @@ func TestDropOriginLocation(t *testing.T) {
 	assert.Error(t, err)
 	assert.ElementsMatch(t, expected, actual)
 }
+
+func TestMultipleHDOPFields(t *testing.T) {
+	now := time.Now()
+	const obdHDOP = "obdHDOP"
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(10 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute + 10*time.Millisecond), Name: obdHDOP, ValueNumber: 2.5},
+	}
+
+	actual, err := ProcessSignals(input, WithHDOPFields(vss.FieldDIMOAftermarketHDOP, obdHDOP))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 2.5}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestHDOPPriority(t *testing.T) {
+	now := time.Now()
+	const obdHDOP = "obdHDOP"
+
+	// The OBD accuracy is closer to the first fix, but the aftermarket
+	// HDOP is still in the window. The second fix has only the OBD one.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(10 * time.Millisecond), Name: obdHDOP, ValueNumber: 4},
+		{TokenID: 3, Timestamp: now.Add(200 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute + 10*time.Millisecond), Name: obdHDOP, ValueNumber: 2.5},
+	}
+
+	actual, err := ProcessSignals(input, WithHDOPPriority(vss.FieldDIMOAftermarketHDOP, obdHDOP))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 2.5}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestOutputOrder(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldSpeed, ValueNumber: 30},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(3 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldSpeed, ValueNumber: 30},
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	assert.Error(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestDroppedKeepsOriginalName(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	store := newStore(input, DefaultConfig())
+	_, err := store.processSignals()
+
+	assert.Error(t, err)
+	if assert.Len(t, store.dropped, 2) {
+		assert.Equal(t, vss.FieldCurrentLocationLatitude, store.dropped[0].Name)
+		assert.Equal(t, vss.FieldCurrentLocationLongitude, store.dropped[1].Name)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	var dropped []string
+	var reasons []string
+	var created []vss.Signal
+
+	_, err := ProcessSignals(input,
+		WithOnDrop(func(sig vss.Signal, reason string) {
+			dropped = append(dropped, sig.Name)
+			reasons = append(reasons, reason)
+		}),
+		WithOnCreate(func(loc vss.Signal) {
+			created = append(created, loc)
+		}),
+	)
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{vss.FieldCurrentLocationLatitude, vss.FieldCurrentLocationLongitude, vss.FieldCurrentLocationLongitude}, dropped)
+	assert.Equal(t, []string{ReasonOrigin, ReasonOrigin, ReasonUnpaired}, reasons)
+	assert.Equal(t, []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}, created)
+}
+
+func TestCustomCoordinatesField(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input, WithCoordinatesField("currentLocation"))
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: "currentLocation", ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestDropRepeatedFixes(t *testing.T) {
+	now := time.Now()
+
+	var input []vss.Signal
+	for i := range 5 {
+		ts := now.Add(time.Duration(i) * time.Minute)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		)
+	}
+
+	actual, err := ProcessSignals(input, WithDropRepeatedFixes(false))
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestDropRepeatedFixesCompareHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+	}
+
+	actual, err := ProcessSignals(input, WithDropRepeatedFixes(true))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 2.5}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestEarlyHDOPAttaches(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(-300 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(250 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestLateHDOPJoinsEarlierPair(t *testing.T) {
+	now := time.Now()
+
+	// The HDOP sorts after the first pair is complete, and after the
+	// second has begun. It still belongs to the first pair, which is
+	// nearest. The second pair has no HDOP of its own, so it gets
+	// the same one.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(200 * time.Millisecond), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(201 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(5 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(200 * time.Millisecond), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 1.5}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestNearestHDOPWins(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(-300 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 2.5}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestDistantHDOPNotAttached(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(-600 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestStoreReset(t *testing.T) {
+	now := time.Now()
+
+	first := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+
+	second := []vss.Signal{
+		{TokenID: 4, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 4, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	store := MustNew(first)
+
+	actual, err := store.Process()
+
+	assert.Error(t, err)
+	assert.Empty(t, actual)
+
+	store.Reset(second)
+
+	actual, err = store.Process()
+
+	expected := append(second, vss.Signal{TokenID: 4, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+	assert.Empty(t, store.dropped)
+}
+
+func TestProcessAllMatchesProcessSignals(t *testing.T) {
+	clock := WithClock(func() time.Time { return time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC) })
+	input := benchSignals(1000)
+
+	expected, expectedErr := ProcessSignals(input, clock)
+
+	store := MustNew(slices.Clone(input), clock)
+	actual := store.ProcessAll()
+
+	assert.Equal(t, expectedErr, store.Err())
+	assert.Equal(t, expected, actual)
+}
+
+func TestTryCreateLocationClearsPair(t *testing.T) {
+	assertCleared := func(t *testing.T, store *Store) {
+		t.Helper()
+		assert.Equal(t, -1, store.lastLat)
+		assert.Equal(t, -1, store.lastLon)
+		assert.Equal(t, -1, store.lastStart)
+		assert.Zero(t, store.lastTime)
+	}
+
+	for seed := range uint64(50) {
+		input := locgentest.GenerateTrack(locgentest.TrackOptions{
+			Seed:        seed,
+			Fixes:       20,
+			Skew:        300 * time.Millisecond,
+			Duplicates:  3,
+			OriginFixes: 2,
+		})
+
+		store := MustNew(input, WithRequireHDOP(), WithMaxHDOP(2))
+		store.ProcessAll()
+		assertCleared(t, store)
+
+		// Every way out of tryCreateLocation, from a complete pair to
+		// a lone coordinate, must leave no trace of the pair behind.
+		r := rand.New(rand.NewPCG(seed, 629))
+		for range 50 {
+			store.lastLat, store.lastLon = r.IntN(len(input)+1)-1, r.IntN(len(input)+1)-1
+			if store.lastLat == -1 && store.lastLon == -1 {
+				continue
+			}
+			store.lastStart = store.lastLat
+			if store.lastStart == -1 || store.lastLon != -1 && store.lastLon < store.lastStart {
+				store.lastStart = store.lastLon
+			}
+			store.lastTime = store.signals[store.lastStart].Timestamp
+
+			store.tryCreateLocation()
+			assertCleared(t, store)
+		}
+	}
+}
+
+func TestProcessSignalsWithHDOP(t *testing.T) {
+	now := time.Now()
+
+	var positions []vss.Signal
+	for i := range 8 {
+		ts := now.Add(time.Duration(i) * time.Second)
+		positions = append(positions,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42 + float64(i)/1000},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83},
+		)
+	}
+
+	hdops := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(5400 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	origPositions, origHDOPs := slices.Clone(positions), slices.Clone(hdops)
+
+	actual, err := ProcessSignalsWithHDOP(positions, hdops)
+
+	assert.Equal(t, origPositions, positions)
+	assert.Equal(t, origHDOPs, hdops)
+
+	hdopByTime := make(map[time.Duration]float64)
+	for _, sig := range actual {
+		if sig.Name == fieldCoordinates {
+			hdopByTime[sig.Timestamp.Sub(now)] = sig.ValueLocation.HDOP
+		}
+	}
+
+	assert.NoError(t, err)
+	assert.Len(t, actual, 16+8)
+	assert.Equal(t, map[time.Duration]float64{
+		0:               1.5,
+		time.Second:     0,
+		2 * time.Second: 0,
+		3 * time.Second: 0,
+		4 * time.Second: 0,
+		5 * time.Second: 2.5,
+		6 * time.Second: 0,
+		7 * time.Second: 0,
+	}, hdopByTime)
+}
+
+func TestProcessSignalsWithNoHDOP(t *testing.T) {
+	now := time.Now()
+
+	// The inline HDOP is ignored either way.
+	positions := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	expected := append(slices.Clone(positions), vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	for name, hdops := range map[string][]vss.Signal{"nil": nil, "empty": {}} {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ProcessSignalsWithHDOP(positions, hdops)
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expected, actual)
+		})
+	}
+}
+
+func TestDropFutureSignals(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Hour - time.Second), Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Hour + time.Second), Name: vss.FieldSpeed, ValueNumber: 60},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Hour - time.Second), Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	actual, err := ProcessSignals(input, WithClock(func() time.Time { return now }))
+
+	assert.Error(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestFutureBoundary(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		kept   bool
+	}{
+		{"just inside", defaultMaxFuture - time.Nanosecond, true},
+		{"exactly at", defaultMaxFuture, true},
+		{"just past", defaultMaxFuture + time.Nanosecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := []vss.Signal{
+				{TokenID: 3, Timestamp: now.Add(tt.offset), Name: vss.FieldSpeed, ValueNumber: 55},
+			}
+
+			actual, err := ProcessSignals(input, WithClock(func() time.Time { return now }))
+
+			if tt.kept {
+				assert.NoError(t, err)
+				assert.Equal(t, input, actual)
+			} else {
+				var future *FutureError
+				assert.ErrorAs(t, err, &future)
+				assert.Empty(t, actual)
+			}
+		})
+	}
+}
+
+func TestConfidencePropagation(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	// Until vss.Signal has a confidence field, keep it on the side.
+	confidence := map[string]float64{
+		vss.FieldCurrentLocationLatitude:  0.9,
+		vss.FieldCurrentLocationLongitude: 0.8,
+		vss.FieldDIMOAftermarketHDOP:      0.6,
+	}
+	var set []float64
+
+	_, err := ProcessSignals(input, WithConfidence(
+		func(sig vss.Signal) float64 { return confidence[sig.Name] },
+		func(_ *vss.Signal, conf float64) { set = append(set, conf) },
+	))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.6}, set)
+}
+
+func TestFailFast(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}
+
+	actual, err := ProcessSignals(input, WithFailFast())
+
+	assert.Error(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestFailFastAccounting(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	_, stats, err := ProcessSignalsWithStats(input, WithFailFast())
+	assert.Error(t, err)
+	assert.Equal(t, map[string]int{ReasonOrigin: 2, ReasonFailFast: 1}, stats.Dropped)
+
+	res, err := ProcessSignalsVerbose(input, WithFailFast())
+	assert.Error(t, err)
+
+	r, err := Reconcile(input, res)
+	assert.NoError(t, err)
+	assert.Equal(t, Reconciliation{InputCount: 3, DroppedCount: 3}, r)
+}
+
+func TestOrderBySequence(t *testing.T) {
+	now := time.Now()
+
+	// The device puts a sequence counter in the CloudEvent ID and
+	// stamps everything with the receipt time.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, CloudEventID: "1"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, CloudEventID: "2"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459, CloudEventID: "10"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145, CloudEventID: "11"},
+	}
+
+	seq := func(sig vss.Signal) int64 {
+		n, _ := strconv.ParseInt(sig.CloudEventID, 10, 64)
+		return n
+	}
+
+	actual, err := ProcessSignals(input, WithOrderBy(seq, 3))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, CloudEventID: "1"},
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}, CloudEventID: "1"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestSortLessByToken(t *testing.T) {
+	now := time.Now()
+
+	// Two vehicles report at nearly the same times. In plain time
+	// order the latitude of one would pair with the longitude of the
+	// other.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1},
+		{TokenID: 4, Timestamp: now.Add(50 * time.Millisecond), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 4, Timestamp: now.Add(50 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 9},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 4, Timestamp: now.Add(150 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	byToken := func(a, b vss.Signal) int {
+		return cmp.Or(cmp.Compare(a.TokenID, b.TokenID), instant(a.Timestamp).Compare(instant(b.Timestamp)), cmp.Compare(a.Name, b.Name))
+	}
+
+	actual, err := ProcessSignals(input, WithSortLess(byToken))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1}},
+		vss.Signal{TokenID: 4, Timestamp: now.Add(50 * time.Millisecond), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 9}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+
+	// The kept signals come out in the custom order.
+	var tokens []uint32
+	for _, sig := range actual[:len(input)] {
+		tokens = append(tokens, sig.TokenID)
+	}
+	assert.Equal(t, []uint32{3, 3, 3, 4, 4, 4}, tokens)
+}
+
+func TestSentinelNameSurvives(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: "__drop", ValueNumber: 1},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: "__drop", ValueNumber: 1},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	assert.Error(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestMaxHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 25},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 3},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignals(input, WithMaxHDOP(20))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 3}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	var poorFix *PoorFixError
+	if assert.ErrorAs(t, err, &poorFix) {
+		assert.Equal(t, 25.0, poorFix.HDOP)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestProcessSignalsDoesNotModifyInput(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+	original := slices.Clone(input)
+
+	actual, err := ProcessSignals(input)
+	assert.Error(t, err)
+
+	for i := range actual {
+		actual[i].Name = "overwritten"
+		actual[i].ValueNumber = -1
+	}
+
+	assert.Equal(t, original, input)
+}
+
+func TestPrecombinedCoordinates(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 0, Longitude: 0, HDOP: 1.5}},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+	}
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	var origin *OriginError
+	assert.ErrorAs(t, err, &origin)
+	assert.Equal(t, expected, actual)
+}
+
+func TestRequireHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 3},
+	}
+
+	actual, err := ProcessSignals(input, WithRequireHDOP())
+
+	expected := append(input[2:],
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 3}},
+	)
+
+	var missing *HDOPMissingError
+	if assert.ErrorAs(t, err, &missing) {
+		assert.Equal(t, now, missing.Time)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestWithoutHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 30},
+	}
+
+	// Neither the HDOP nor the options that depend on it have any
+	// effect.
+	actual, stats, err := ProcessSignalsWithStats(input, WithoutHDOP(), WithRequireHDOP(), WithMaxHDOP(5), WithConsumeHDOP())
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+	assert.Zero(t, stats.OrphanedHDOP)
+}
+
+func TestSampleCount(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 3},
+	}
+
+	// Stash the count somewhere visible on the signal.
+	actual, err := ProcessSignals(input, WithSampleCount(func(sig *vss.Signal, n int) {
+		sig.Producer = strconv.Itoa(n)
+	}))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, Producer: "2"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 3}, Producer: "3"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestValueType(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	// Until vss.Signal has a discriminator, ValueString stands in.
+	actual, err := ProcessSignals(input, WithValueType(func(sig *vss.Signal) {
+		sig.ValueString = "location"
+	}))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, ValueString: "location"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestCreatedKeepsTemplateFields(t *testing.T) {
+	now := time.Now()
+
+	lat := vss.Signal{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395}
+
+	// Fill in every other string field, including any that vss.Signal
+	// gains later.
+	overwritten := []string{"Name", "ValueString"}
+	v := reflect.ValueOf(&lat).Elem()
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if f.Type.Kind() == reflect.String && !slices.Contains(overwritten, f.Name) {
+			v.Field(i).SetString(f.Name)
+		}
+	}
+
+	input := []vss.Signal{
+		lat,
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	actual, err := ProcessSignalsVerbose(input)
+
+	assert.NoError(t, err)
+	if assert.Len(t, actual.Created, 1) {
+		expected := lat
+		expected.Name = fieldCoordinates
+		expected.ValueNumber = 0
+		expected.ValueLocation = vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}
+		assert.Equal(t, expected, actual.Created[0])
+	}
+}
+
+func TestInterpolatedHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(-100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute + 200*time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 4},
+	}
+
+	actual, err := ProcessSignals(input, WithInterpolatedHDOP())
+
+	// The second location only has an HDOP on one side.
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 2}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 4}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestNoLocationFastPath(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	clock := WithClock(func() time.Time { return now })
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(-time.Minute), Name: vss.FieldPowertrainTransmissionTravelledDistance, ValueNumber: 10000},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Hour), Name: vss.FieldSpeed, ValueNumber: 60},
+	}
+
+	actual, err := ProcessSignals(input, clock)
+
+	// Strict mode forces the full path, and there are no near misses
+	// here to change the outcome.
+	expected, expectedErr := ProcessSignals(input, clock, WithStrictFields())
+
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, expected, actual)
+	assert.Len(t, actual, 3)
+}
+
+func TestHDOPOnly(t *testing.T) {
+	now := time.Now()
+
+	single := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+	several := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2},
+	}
+
+	// Strict mode forces the full pairing path.
+	for _, opts := range [][]Option{nil, {WithStrictFields()}} {
+		actual, err := ProcessSignals(single, opts...)
+
+		assert.NoError(t, err)
+		assert.Equal(t, single, actual)
+
+		actual, err = ProcessSignals(several, opts...)
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []vss.Signal{several[0], several[2]}, actual)
+	}
+}
+
+func TestDefaultSourceAndProducer(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, Source: "0xA", Producer: "did:a"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, Source: "0xA", Producer: "did:a"},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145, Source: "0xB", Producer: "did:b"},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459, Source: "0xB", Producer: "did:b"},
+	}
+
+	actual, err := ProcessSignals(input, WithDefaultSource("ingest"), WithDefaultProducer("trace-123"))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, Source: "ingest", Producer: "trace-123"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}, Source: "ingest", Producer: "trace-123"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestDSTBoundaryPairing(t *testing.T) {
+	// The night that New York falls back, 01:59 EDT is followed by
+	// 01:00 EST. By the local clock, these two signals are an hour
+	// apart and in the wrong order; in fact they're 200ms apart.
+	edt := time.FixedZone("EDT", -4*60*60)
+	est := time.FixedZone("EST", -5*60*60)
+	lat := time.Date(2025, 11, 2, 1, 59, 59, 900_000_000, edt)
+	lon := time.Date(2025, 11, 2, 1, 0, 0, 100_000_000, est)
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: lon, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: lat, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+
+	actual, err := ProcessSignals(input, WithCheckTimeOrder())
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: lat, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	// The longitude came first in the input, so the latitude is
+	// flagged, but the pair is still made.
+	var backwards *BackwardsTimeWarning
+	if assert.ErrorAs(t, err, &backwards) {
+		assert.Equal(t, lat, backwards.Time)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestHDOPTieBreak(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(-100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	tests := []struct {
+		name string
+		rule HDOPTieBreak
+		hdop float64
+	}{
+		{"earlier", TieBreakEarlier, 2.5},
+		{"later", TieBreakLater, 1.5},
+		{"lower", TieBreakLower, 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ProcessSignals(input, WithHDOPTieBreak(tt.rule))
+
+			expected := append(slices.Clone(input), vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: tt.hdop}})
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expected, actual)
+		})
+	}
+}
+
+func TestMaxLocationsPerToken(t *testing.T) {
+	now := time.Now()
+
+	var input []vss.Signal
+	for i := range 3 {
+		ts := now.Add(time.Duration(i) * time.Second)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395 + float64(i)/1000},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		)
+	}
+
+	actual, err := ProcessSignals(input, WithMaxLocationsPerToken(2))
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395 + 1.0/1000, Longitude: -83.06028627110183, HDOP: 1.5}},
+	)
+
+	var capped *CapExceededError
+	if assert.ErrorAs(t, err, &capped) {
+		assert.Equal(t, now.Add(2*time.Second), capped.Time)
+	}
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 1)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestLocationTimestamp(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(-50 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+
+	tests := []struct {
+		name string
+		rule LocationTimestamp
+		time time.Time
+	}{
+		{"earliest", TimestampEarliest, now},
+		{"latitude", TimestampLatitude, now.Add(100 * time.Millisecond)},
+		{"longitude", TimestampLongitude, now},
+		{"latest", TimestampLatest, now.Add(100 * time.Millisecond)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ProcessSignals(input, WithLocationTimestamp(tt.rule))
+
+			expected := append(slices.Clone(input), vss.Signal{TokenID: 3, Timestamp: tt.time, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}})
+
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, expected, actual)
+		})
+	}
+}
+
+func TestMonotonicLocationTimestamps(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, CloudEventID: "1"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, CloudEventID: "2"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145, CloudEventID: "10"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459, CloudEventID: "11"},
+	}
+
+	seq := func(sig vss.Signal) int64 {
+		n, _ := strconv.ParseInt(sig.CloudEventID, 10, 64)
+		return n
+	}
+
+	actual, err := ProcessSignals(input, WithOrderBy(seq, 3), WithMonotonicLocationTimestamps())
+
+	expected := append(slices.Clone(input),
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, CloudEventID: "1"},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Nanosecond), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}, CloudEventID: "1"},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestSourceConsistency(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, Source: "gps-a"},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, Source: "gps-b"},
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		actual, err := ProcessSignals(input)
+
+		expected := append(slices.Clone(input), vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, Source: "gps-a"})
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, expected, actual)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		actual, err := ProcessSignals(input, WithSourceConsistency())
+
+		var mismatch *SourceMismatchError
+		if assert.ErrorAs(t, err, &mismatch) {
+			assert.Equal(t, "gps-a", mismatch.LatitudeSource)
+			assert.Equal(t, "gps-b", mismatch.LongitudeSource)
+		}
+		assert.Empty(t, actual)
+	})
+}
+
+func TestZeroHDOPIgnored(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 0},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	var negative *NegativeHDOPError
+	if assert.ErrorAs(t, err, &negative) {
+		assert.Zero(t, negative.HDOP)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestNegativeHDOPIgnored(t *testing.T) {
+	now := time.Now()
+
+	positions := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+	hdop := vss.Signal{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: -1}
+	location := vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}}
+
+	t.Run("inline", func(t *testing.T) {
+		// The HDOP is dropped before it can be attached.
+		actual, err := ProcessSignals(append(slices.Clone(positions), hdop))
+
+		var invalid *InvalidHDOPError
+		assert.ErrorAs(t, err, &invalid)
+		var negative *NegativeHDOPError
+		assert.False(t, errors.As(err, &negative))
+		assert.ElementsMatch(t, append(slices.Clone(positions), location), actual)
+	})
+
+	t.Run("separate", func(t *testing.T) {
+		// The HDOP is not attached, though there's nothing to drop.
+		actual, err := ProcessSignalsWithHDOP(slices.Clone(positions), []vss.Signal{hdop})
+
+		var invalid *InvalidHDOPError
+		if assert.ErrorAs(t, err, &invalid) {
+			assert.Equal(t, -1.0, invalid.HDOP)
+		}
+		assert.ElementsMatch(t, append(slices.Clone(positions), location), actual)
+	})
+}
+
+func TestInvalidHDOPDropped(t *testing.T) {
+	for _, hdop := range []float64{-1, math.NaN(), math.Inf(1)} {
+		t.Run(strconv.FormatFloat(hdop, 'g', -1, 64), func(t *testing.T) {
+			now := time.Now()
+
+			// The bad HDOP is a minute away from the only pair, so it
+			// never comes near the pairing.
+			input := []vss.Signal{
+				{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+				{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+				{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: hdop},
+			}
+
+			actual, err := ProcessSignals(input)
+
+			expected := append(input[:2:2], vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+			var invalid *InvalidHDOPError
+			if assert.ErrorAs(t, err, &invalid) {
+				assert.Equal(t, now.Add(time.Minute), invalid.Time)
+			}
+			assert.ElementsMatch(t, expected, actual)
+		})
+	}
+}
+
+func TestLoneInvalidHDOPDropped(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: math.NaN()},
+	}
+
+	actual, stats, err := ProcessSignalsWithStats(input)
+
+	var invalid *InvalidHDOPError
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, input[:1], actual)
+	assert.Equal(t, 1, stats.Dropped[ReasonInvalidHDOP])
+}
+
+func TestConsumeHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+	}
+
+	res, err := ProcessSignalsVerbose(slices.Clone(input), WithConsumeHDOP())
+
+	// The unused HDOP stays.
+	expected := []vss.Signal{
+		input[0],
+		input[1],
+		input[3],
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+	}
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, res.Signals)
+	assert.Equal(t, []vss.Signal{input[2]}, res.Dropped)
+}
+
+func TestSimultaneityWindow(t *testing.T) {
+	now := time.Now()
+
+	// A latitude from one fix and a longitude from the next, with a
+	// shared HDOP.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(150 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	assert.NoError(t, err)
+	assert.Len(t, actual, 4)
+
+	actual, err = ProcessSignals(input, WithSimultaneityWindow(50*time.Millisecond))
+
+	var unpaired *UnpairedError
+	assert.ErrorAs(t, err, &unpaired)
+	assert.Equal(t, []vss.Signal{input[2]}, actual)
+}
+
+func TestSimultaneityWindowKeepsHDOPGap(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(10 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(300 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	actual, err := ProcessSignals(input, WithSimultaneityWindow(50*time.Millisecond))
+
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestHDOPGap(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(1500 * time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	t.Run("default", func(t *testing.T) {
+		actual, err := ProcessSignals(input)
+
+		expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, expected, actual)
+	})
+
+	t.Run("widened", func(t *testing.T) {
+		actual, err := ProcessSignals(input, WithHDOPGap(2*time.Second))
+
+		expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}})
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, expected, actual)
+	})
+}
+
+func TestLocationsOnly(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+
+	res, err := ProcessSignalsVerbose(slices.Clone(input), WithLocationsOnly(), WithTravelledDistance("travelled", 0))
+
+	expected := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}},
+	}
+
+	// The unpaired latitude is still reported.
+	assert.Error(t, err)
+	assert.Equal(t, expected, res.Signals)
+	assert.Len(t, res.Signals, len(res.Created))
+}
+
+func TestStaleValueLocationIgnored(t *testing.T) {
+	now := time.Now()
+
+	junk := vss.Location{Latitude: 1, Longitude: 2, HDOP: 99}
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, ValueLocation: junk},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, ValueLocation: junk},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	// The originals pass through untouched, junk and all.
+	expected := append(input, vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
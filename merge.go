@@ -0,0 +1,93 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// MergeBoundary joins the outputs of two ProcessSignals calls on
+// adjacent time windows, a before b, and creates the locations whose
+// parts were split between them. Only the signals within
+// cfg.LocationGap of the seam are processed again; the rest are passed
+// through as they are.
+//
+// ProcessSignals drops unpaired latitudes and longitudes, so for them
+// to be recovered here each window's output should have its unpaired
+// signals, from Result.Dropped of ProcessSignalsVerbose, added back.
+// Those near the seam that still find no partner are dropped again,
+// with an UnpairedError.
+//
+// The passes that look at the created locations as a whole, like
+// cfg.MinDistance and cfg.DistanceField, are not rerun, and the
+// callbacks see the boundary region a second time. Start from
+// DefaultConfig rather than a zero Config: a Config that fails
+// Config.Validate is rejected with its error.
+//
+// Neither input slice is modified.
+func MergeBoundary(a, b []vss.Signal, cfg Config) ([]vss.Signal, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfg.MinDistance = 0
+	cfg.DistanceField = ""
+	cfg.GapMarkerThreshold = 0
+	cfg.AccuracyUERE = 0
+	cfg.FixTTL = 0
+	cfg.SmoothingWindow = 0
+	cfg.SatellitesField = ""
+	cfg.BoundingBoxSignal = false
+	cfg.MaxLocationsPerToken = 0
+	cfg.LocationsOnly = false
+
+	store := newStore(nil, cfg)
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	store.sort(a)
+	store.sort(b)
+
+	if len(a) == 0 || len(b) == 0 {
+		return append(a, b...), nil
+	}
+
+	// Find the signals within the window of the seam on either side.
+	last, first := a[len(a)-1], b[0]
+	aStart := len(a)
+	for aStart > 0 && store.span(a[aStart-1], last) < store.window() {
+		aStart--
+	}
+	bEnd := 0
+	for bEnd < len(b) && store.span(first, b[bEnd]) < store.window() {
+		bEnd++
+	}
+
+	region := append(slices.Clone(a[aStart:]), b[:bEnd]...)
+
+	// Locations that already exist in the region are made again, so
+	// we only keep the new ones.
+	type key struct {
+		tokenID uint32
+		time    time.Time
+	}
+	existing := make(map[key]struct{})
+	for _, sig := range region {
+		if sig.Name == cfg.CoordinatesField {
+			existing[key{sig.TokenID, instant(sig.Timestamp)}] = struct{}{}
+		}
+	}
+
+	store.Reset(region)
+	regionOut, err := store.processSignals()
+
+	out := append(a[:aStart], regionOut[:len(regionOut)-len(store.created)]...)
+	for _, loc := range store.created {
+		if _, ok := existing[key{loc.TokenID, instant(loc.Timestamp)}]; !ok {
+			out = append(out, loc)
+		}
+	}
+	out = append(out, b[bEnd:]...)
+
+	return out, err
+}
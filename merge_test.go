@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeBoundary(t *testing.T) {
+	now := time.Now()
+
+	first := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(10 * time.Second), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+	}
+	second := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(10*time.Second + 100*time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(10*time.Second + 200*time.Millisecond), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.2},
+		{TokenID: 3, Timestamp: now.Add(20 * time.Second), Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	resA, _ := ProcessSignalsVerbose(slices.Clone(first))
+	resB, _ := ProcessSignalsVerbose(slices.Clone(second))
+	if !assert.Len(t, resA.Dropped, 1) || !assert.Len(t, resB.Dropped, 1) {
+		return
+	}
+
+	a := append(resA.Signals, resA.Dropped...)
+	b := append(resB.Signals, resB.Dropped...)
+
+	out, err := MergeBoundary(a, b, DefaultConfig())
+
+	assert.NoError(t, err)
+	expected := append(append(first, second...),
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(10 * time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 1.2}},
+	)
+	assert.ElementsMatch(t, expected, out)
+}
+
+func TestMergeBoundaryStillUnpaired(t *testing.T) {
+	now := time.Now()
+
+	a := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+	b := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Second), Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	out, err := MergeBoundary(a, b, DefaultConfig())
+
+	var unpaired *UnpairedError
+	assert.ErrorAs(t, err, &unpaired)
+	assert.Equal(t, b, out)
+}
+
+func TestMergeBoundaryInvalidConfig(t *testing.T) {
+	now := time.Now()
+
+	a := []vss.Signal{{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395}}
+	b := []vss.Signal{{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183}}
+
+	actual, err := MergeBoundary(a, b, Config{})
+
+	var cfgErr *ConfigError
+	assert.ErrorAs(t, err, &cfgErr)
+	assert.Nil(t, actual)
+}
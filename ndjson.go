@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// ProcessSignalsToNDJSON runs ProcessSignals and writes the output to
+// w as newline-delimited JSON, one signal per line. Since the output of
+// ProcessSignals is meaningful even when there is an error, all of it
+// is written before the processing error is returned. If writing
+// fails, we stop and return that error too.
+//
+// Each signal results in a separate Write call, so callers may want to
+// pass a bufio.Writer.
+func ProcessSignalsToNDJSON(w io.Writer, signals []vss.Signal, opts ...Option) error {
+	out, err := ProcessSignals(signals, opts...)
+
+	enc := json.NewEncoder(w)
+	for _, sig := range out {
+		if encErr := enc.Encode(sig); encErr != nil {
+			return errors.Join(encErr, err)
+		}
+	}
+
+	return err
+}
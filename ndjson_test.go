@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessSignalsToNDJSON(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+	}
+
+	var buf bytes.Buffer
+	err := ProcessSignalsToNDJSON(&buf, input)
+
+	// The unpaired latitude is an error, but everything else is
+	// still written.
+	assert.Error(t, err)
+
+	var names []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var sig vss.Signal
+		if assert.NoError(t, json.Unmarshal(scanner.Bytes(), &sig)) {
+			names = append(names, sig.Name)
+		}
+	}
+
+	assert.ElementsMatch(t, []string{vss.FieldCurrentLocationLatitude, vss.FieldCurrentLocationLongitude, vss.FieldSpeed, fieldCoordinates}, names)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// pairSimultaneous pairs up the latitudes and longitudes that share a
+// TokenID and an exact timestamp, where there is more than one of
+// either: the first latitude with the first longitude, and so on, in
+// input order. The time-based pass would instead let the second
+// latitude close the pair of the first before any longitude was seen.
+// Any left over are left to it.
+func (c *Store) pairSimultaneous() {
+	for start := 0; start < len(c.signals); {
+		end := start
+		lats, lons := 0, 0
+		for end < len(c.signals) && c.span(c.signals[start], c.signals[end]) == 0 {
+			switch c.signals[end].Name {
+			case vss.FieldCurrentLocationLatitude:
+				lats++
+			case vss.FieldCurrentLocationLongitude:
+				lons++
+			}
+			end++
+		}
+
+		if lats > 1 || lons > 1 {
+			c.pairRun(start, end)
+		}
+		start = end
+	}
+}
+
+// pairRun does the work of pairSimultaneous for the signals in
+// [start, end), which all have the same timestamp.
+func (c *Store) pairRun(start, end int) {
+	type coords struct {
+		token      uint32
+		lats, lons []int
+	}
+	var byToken []coords
+
+	for i := start; i < end; i++ {
+		sig := c.signals[i]
+		if !isCoordinate(sig.Name) || !c.pairable(i) {
+			continue
+		}
+		k := slices.IndexFunc(byToken, func(cs coords) bool { return cs.token == sig.TokenID })
+		if k == -1 {
+			byToken = append(byToken, coords{token: sig.TokenID})
+			k = len(byToken) - 1
+		}
+		if sig.Name == vss.FieldCurrentLocationLatitude {
+			byToken[k].lats = append(byToken[k].lats, i)
+		} else {
+			byToken[k].lons = append(byToken[k].lons, i)
+		}
+	}
+
+	for _, cs := range byToken {
+		if len(cs.lats) < 2 && len(cs.lons) < 2 {
+			continue
+		}
+		for k := range min(len(cs.lats), len(cs.lons)) {
+			c.prepair(cs.lats[k], cs.lons[k], -1)
+		}
+	}
+}
+
+// pairable reports whether the signal at the given index is free to be
+// paired ahead of the time-based pass. Signals too far in the future
+// are left for that pass to drop.
+func (c *Store) pairable(index int) bool {
+	if c.isDropped[index] || len(c.prepaired) != 0 && c.prepaired[index] {
+		return false
+	}
+	return checkFuture(c.signals[index], c.cfg.MaxFuture, c.now) == nil
+}
+
+// pairNearest pairs each latitude, in time order, with the nearest
+// unused longitude for the same TokenID within the pair window, under
+// cfg.NearestPairing. Ties go to the earlier longitude. Coordinates
+// that find no partner, or are too far in the future, are left to the
+// time-based pass.
+func (c *Store) pairNearest() {
+	var lats, lons []int
+	for i, sig := range c.signals {
+		if !c.pairable(i) {
+			continue
+		}
+		switch sig.Name {
+		case vss.FieldCurrentLocationLatitude:
+			lats = append(lats, i)
+		case vss.FieldCurrentLocationLongitude:
+			lons = append(lons, i)
+		}
+	}
+
+	used := make([]bool, len(lons))
+	for _, lat := range lats {
+		target := c.signals[lat]
+
+		// j is the position of the first longitude at or after lat.
+		j := c.search(c.signals, lons, target)
+
+		best := -1
+		var bestSpan int64
+		consider := func(k int, span int64) {
+			if used[k] || c.signals[lons[k]].TokenID != target.TokenID {
+				return
+			}
+			if best == -1 || span < bestSpan {
+				best, bestSpan = k, span
+			}
+		}
+		for k := j - 1; k >= 0; k-- {
+			span := c.span(c.signals[lons[k]], target)
+			if c.separate(c.signals[lons[k]], target) || span >= c.pairWindow() {
+				break
+			}
+			consider(k, span)
+		}
+		for k := j; k < len(lons); k++ {
+			span := c.span(target, c.signals[lons[k]])
+			if c.separate(target, c.signals[lons[k]]) || span >= c.pairWindow() {
+				break
+			}
+			consider(k, span)
+		}
+
+		if best != -1 {
+			used[best] = true
+			c.prepair(lat, lons[best], -1)
+		}
+	}
+}
+
+// plannedPair is a latitude and longitude paired ahead of the
+// time-based pass, by their indices. If hdop is not -1 then that HDOP
+// is attached in place of the nearest one.
+type plannedPair struct {
+	lat, lon, hdop int
+}
+
+// start returns the index of the earlier signal in the pair.
+func (p plannedPair) start() int {
+	return min(p.lat, p.lon)
+}
+
+// end returns the index of the later signal in the pair.
+func (p plannedPair) end() int {
+	return max(p.lat, p.lon)
+}
+
+// prepair marks the latitude and longitude at the given indices in
+// prepaired, so that the time-based pass leaves them alone, and plans
+// a location from them.
+func (c *Store) prepair(lat, lon, hdop int) {
+	if len(c.prepaired) == 0 {
+		c.prepaired = slices.Grow(c.prepaired[:0], len(c.signals))[:len(c.signals)]
+		clear(c.prepaired)
+	}
+	c.prepaired[lat] = true
+	c.prepaired[lon] = true
+	c.planned = append(c.planned, plannedPair{lat: lat, lon: lon, hdop: hdop})
+}
+
+// createPlanned makes the location for p. The time-based pass may have
+// a pair of its own open, so that is set aside and then put back.
+func (c *Store) createPlanned(p plannedPair) {
+	lat, lon, start, t := c.lastLat, c.lastLon, c.lastStart, c.lastTime
+
+	if c.cfg.WrapLongitude {
+		c.signals[p.lon].ValueNumber = wrapLongitude(c.signals[p.lon].ValueNumber)
+	}
+	c.lastLat, c.lastLon = p.lat, p.lon
+	c.lastStart = p.start()
+	c.lastTime = c.signals[c.lastStart].Timestamp
+	c.keyedHDOP = p.hdop
+
+	c.tryCreateLocation()
+	c.keyedHDOP = -1
+
+	c.lastLat, c.lastLon, c.lastStart, c.lastTime = lat, lon, start, t
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearestPairing(t *testing.T) {
+	now := time.Now()
+
+	// Both latitudes arrive before either longitude. Resetting on the
+	// second latitude would lose the first fix.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(200 * time.Millisecond), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(250 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(450 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	actual, err := ProcessSignals(input, WithNearestPairing())
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(200 * time.Millisecond), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}},
+	)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestNearestPairingLeavesRest(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+	}
+
+	actual, err := ProcessSignals(input, WithNearestPairing())
+
+	expected := append(input[:2:2],
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	var unpaired *UnpairedError
+	assert.ErrorAs(t, err, &unpaired)
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestSimultaneousCoordinates(t *testing.T) {
+	now := time.Now()
+
+	// Two fixes stamped with the same time, as a buffered tracker
+	// sometimes sends them. They pair up in input order.
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	actual, err := ProcessSignals(input)
+
+	expected := append(input,
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183, HDOP: 1.5}},
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459, HDOP: 1.5}},
+	)
+
+	// The only complaint is that the values conflict, which they do.
+	var conflict *ConflictWarning
+	assert.ErrorAs(t, err, &conflict)
+	var unpaired *UnpairedError
+	assert.False(t, errors.As(err, &unpaired))
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestFailFastSimultaneousCoordinates(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.336},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.076},
+	}
+
+	actual, err := ProcessSignals(input, WithFailFast())
+
+	var unpaired *UnpairedError
+	assert.ErrorAs(t, err, &unpaired)
+	var conflict *ConflictWarning
+	assert.False(t, errors.As(err, &conflict))
+	assert.Empty(t, actual)
+}
+
+func TestFailFastNearestPairing(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	actual, err := ProcessSignals(input, WithNearestPairing(), WithFailFast())
+
+	expected := append(input[:2:2],
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	)
+
+	var origin *OriginError
+	assert.ErrorAs(t, err, &origin)
+	assert.ElementsMatch(t, expected, actual)
+}
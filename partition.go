@@ -0,0 +1,15 @@
+package main
+
+import "github.com/DIMO-Network/model-garage/pkg/vss"
+
+// Partition splits the output of ProcessSignalsVerbose into the input
+// signals that were kept and the signals that were created: the
+// locations, followed by any signals derived from them. It goes by
+// where each signal came from rather than by its name, so a combined
+// location passed in counts as an original, and a smoothed location as
+// created. Both halves are subslices of res.Signals, in their original
+// order. To get just the created locations, use res.Created.
+func Partition(res Result) (originals, created []vss.Signal) {
+	n := len(res.Signals) - len(res.Created) - len(res.Derived)
+	return res.Signals[:n], res.Signals[n:]
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartition(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+	}
+
+	res, err := ProcessSignalsVerbose(input)
+	assert.Error(t, err)
+
+	originals, created := Partition(res)
+
+	assert.Equal(t, []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}, originals)
+	assert.Equal(t, []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}, created)
+}
+
+func TestPartitionDerived(t *testing.T) {
+	now := time.Now()
+
+	// The combined location is an input, and the smoothed location and
+	// expiry are created along with the paired one.
+	combined := vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}}
+	input := []vss.Signal{
+		combined,
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	res, err := ProcessSignalsVerbose(input, WithSmoothing(2), WithFixTTL(time.Minute), WithBoundingBoxSignal())
+	assert.NoError(t, err)
+
+	originals, created := Partition(res)
+
+	assert.Equal(t, []vss.Signal{combined, input[1], input[2]}, originals)
+	assert.Equal(t, vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}}, created[0])
+	assert.Equal(t, append(res.Created, res.Derived...), created)
+	assert.Greater(t, len(res.Derived), 1)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// EncodePolyline groups the location signals in the output of
+// ProcessSignals by TokenID, orders each group by timestamp, and
+// encodes it with Google's Encoded Polyline Algorithm, at the usual
+// precision of five decimal places. Other signals are skipped. Pass
+// the same options that were given to ProcessSignals so that the
+// coordinates field name agrees.
+func EncodePolyline(signals []vss.Signal, opts ...Option) map[uint32]string {
+	cfg := newConfig(opts)
+
+	tracks := make(map[uint32][]vss.Signal)
+	for _, sig := range signals {
+		if sig.Name == cfg.CoordinatesField {
+			tracks[sig.TokenID] = append(tracks[sig.TokenID], sig)
+		}
+	}
+
+	out := make(map[uint32]string, len(tracks))
+	for tokenID, track := range tracks {
+		slices.SortStableFunc(track, func(a, b vss.Signal) int {
+			return instant(a.Timestamp).Compare(instant(b.Timestamp))
+		})
+
+		var b strings.Builder
+		var prevLat, prevLon int64
+		for _, sig := range track {
+			// Deltas are taken between the rounded values, so that
+			// rounding errors don't accumulate along the line.
+			lat := int64(math.Round(sig.ValueLocation.Latitude * 1e5))
+			lon := int64(math.Round(sig.ValueLocation.Longitude * 1e5))
+			encodePolylineValue(&b, lat-prevLat)
+			encodePolylineValue(&b, lon-prevLon)
+			prevLat, prevLon = lat, lon
+		}
+		out[tokenID] = b.String()
+	}
+
+	return out
+}
+
+// encodePolylineValue writes one signed value in the polyline
+// encoding: zigzagged, then split into five-bit chunks, least
+// significant first, each offset by 63 and with 0x20 set on all but
+// the last.
+func encodePolylineValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte(0x20|u&0x1f) + 63)
+		u >>= 5
+	}
+	b.WriteByte(byte(u) + 63)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodePolyline(t *testing.T) {
+	now := time.Now()
+
+	// The example from Google's documentation of the algorithm, given
+	// out of order and mixed with another token and other signals.
+	signals := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 43.252, Longitude: -126.453}},
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 38.5, Longitude: -120.2}},
+		{TokenID: 4, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 38.5, Longitude: -120.2}},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 38.5},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 40.7, Longitude: -120.95}},
+	}
+
+	assert.Equal(t, map[uint32]string{
+		3: "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+		4: "_p~iF~ps|U",
+	}, EncodePolyline(signals))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// Processor handles a stream of batches whose boundaries are chosen by
+// the caller, carrying signals that may still combine with the next
+// batch over to it. Unlike ProcessSignalsChunked, which cuts one slice
+// itself, a Processor never sees the whole stream.
+type Processor struct {
+	store *Store
+	// held holds the signals from the end of the last batch that were
+	// too close to its end to be settled.
+	held []vss.Signal
+	err  error
+	// stats accumulates the stats of every processed batch.
+	stats Stats
+	// last maps each TokenID to the latest location created for it.
+	last map[uint32]vss.Signal
+}
+
+// NewProcessor returns a Processor with the given options. It returns
+// an error if they produce a Config that fails Config.Validate.
+func NewProcessor(opts ...Option) (*Processor, error) {
+	cfg := newConfig(opts)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Processor{store: newStore(nil, cfg)}, nil
+}
+
+// MustNewProcessor is like NewProcessor, but panics if the Config is
+// invalid.
+func MustNewProcessor(opts ...Option) *Processor {
+	p, err := NewProcessor(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// PushBatch processes signals together with any held over from the
+// previous batch, and returns the results that are settled. The
+// signals within the HDOP window of the end of the batch are held for
+// the next call, along with the latitude and longitude of any pair
+// still under construction and the HDOPs near it, so that a latitude
+// at the end of one batch still pairs with a longitude at the start of
+// the next. Everything else is settled, however dense the stream.
+// Config.ReorderWindow makes the held part longer, for streams that
+// deliver some signals late. Call Flush at the end of the stream to
+// process whatever is left.
+//
+// The input slice is not modified, and the returned slice does not
+// share memory with it. The error, if any, is available from Err.
+//
+// Passes that look at the created locations as a whole, like
+// Config.MinDistance and Config.DistanceField, run separately on each
+// batch, and so does the cap of Config.MaxLocationsPerToken. The last
+// known coordinates for Config.LastKnownWindow and the last location
+// for Config.DropRepeatedFixes carry over from one batch to the next.
+func (p *Processor) PushBatch(signals []vss.Signal) []vss.Signal {
+	batch := make([]vss.Signal, 0, len(p.held)+len(signals))
+	batch = append(batch, p.held...)
+	batch = append(batch, signals...)
+
+	p.store.hold = true
+	out := p.process(batch)
+	p.held = append(p.held[:0], p.store.held...)
+	return out
+}
+
+// Flush processes any signals held over from the last batch.
+func (p *Processor) Flush() []vss.Signal {
+	batch := slices.Clone(p.held)
+	p.held = p.held[:0]
+	if batch == nil {
+		batch = []vss.Signal{}
+	}
+	p.store.hold = false
+	return p.process(batch)
+}
+
+// Err returns the error from the last call to PushBatch or Flush, or
+// nil if there was none.
+func (p *Processor) Err() error {
+	return p.err
+}
+
+// Stats returns running totals over every signal processed since the
+// Processor was made or last Reset. Held signals are not counted until
+// they are processed, so after Flush the totals match those of
+// ProcessSignalsWithStats on the whole stream, except where
+// batch-wide passes like Config.MinDistance make the results differ.
+func (p *Processor) Stats() Stats {
+	s := p.stats
+	s.Dropped = maps.Clone(s.Dropped)
+	return s
+}
+
+// LastLocations returns the latest location, by timestamp, created for
+// each TokenID since the Processor was made or last Reset. This is for
+// warming caches of where each vehicle is. The map is a copy.
+func (p *Processor) LastLocations() map[uint32]vss.Signal {
+	return maps.Clone(p.last)
+}
+
+// Reset discards any held signals, the last error, the running stats,
+// the last locations, and what carries over between batches, so that
+// the Processor can start on a new stream.
+func (p *Processor) Reset() {
+	p.store.Reset(nil)
+	p.held = p.held[:0]
+	p.err = nil
+	p.stats = Stats{}
+	clear(p.last)
+}
+
+func (p *Processor) process(signals []vss.Signal) []vss.Signal {
+	input := len(signals)
+	p.store.next(signals)
+	// Config.MaxLocationsPerToken caps each batch.
+	clear(p.store.createdCount)
+	var out []vss.Signal
+	out, p.err = p.store.processSignals()
+	// Held signals are counted when they're processed for good.
+	p.stats.add(p.store.stats(input-len(p.store.held), len(out)))
+
+	for _, loc := range p.store.created {
+		if prev, ok := p.last[loc.TokenID]; !ok || !loc.Timestamp.Before(prev.Timestamp) {
+			if p.last == nil {
+				p.last = make(map[uint32]vss.Signal)
+			}
+			p.last[loc.TokenID] = loc
+		}
+	}
+	return out
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/elffjs/locgen/locgentest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessorPushBatch(t *testing.T) {
+	now := time.Now()
+
+	first := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}
+	second := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Minute + 100*time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldSpeed, ValueNumber: 56},
+	}
+
+	p := MustNewProcessor()
+
+	out := p.PushBatch(first)
+	assert.NoError(t, p.Err())
+	assert.Equal(t, first[:1], out)
+
+	out = p.PushBatch(second)
+	assert.NoError(t, p.Err())
+	assert.ElementsMatch(t, []vss.Signal{
+		first[1],
+		second[0],
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}, out)
+
+	out = p.Flush()
+	assert.NoError(t, p.Err())
+	assert.Equal(t, second[1:], out)
+
+	assert.Empty(t, p.Flush())
+}
+
+func TestProcessorDenseStream(t *testing.T) {
+	// At 10 Hz there is never a gap as wide as the location window to
+	// cut at.
+	input := locgentest.GenerateTrack(locgentest.TrackOptions{
+		Seed:     11,
+		Fixes:    200,
+		Interval: 100 * time.Millisecond,
+		Skew:     50 * time.Millisecond,
+	})
+
+	want, err := ProcessSignals(input)
+	assert.NoError(t, err)
+
+	p := MustNewProcessor()
+	var got []vss.Signal
+	for chunk := range slices.Chunk(input, 30) {
+		out := p.PushBatch(chunk)
+		assert.NoError(t, p.Err())
+		assert.NotEmpty(t, out)
+		assert.Less(t, len(p.held), 30)
+		got = append(got, out...)
+	}
+	got = append(got, p.Flush()...)
+
+	assert.ElementsMatch(t, want, got)
+}
+
+func TestProcessorMatchesSingleBatch(t *testing.T) {
+	start := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	// A parked vehicle that sometimes drops a coordinate, so that
+	// both options depend on what came before the batch.
+	opts := []Option{WithLastKnownPairing(30*time.Second, nil), WithDropRepeatedFixes(false)}
+
+	for seed := range uint64(20) {
+		r := rand.New(rand.NewPCG(seed, 601))
+
+		var input []vss.Signal
+		lat, lon := 42.3314, -83.0458
+		for i := range 60 {
+			ts := start.Add(time.Duration(i) * time.Second)
+			if r.IntN(3) == 0 {
+				lat += 0.0001
+				lon += 0.0001
+			}
+			if r.IntN(5) != 0 {
+				input = append(input, vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: lat})
+			}
+			if r.IntN(5) != 0 {
+				input = append(input, vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: lon})
+			}
+		}
+		size := 1 + r.IntN(20)
+
+		want, _ := ProcessSignals(input, opts...)
+
+		chunked, _ := ProcessSignalsChunked(slices.Clone(input), size, opts...)
+		assert.ElementsMatch(t, want, chunked, "chunked, seed %d", seed)
+
+		p := MustNewProcessor(opts...)
+		var pushed []vss.Signal
+		for chunk := range slices.Chunk(input, size) {
+			pushed = append(pushed, p.PushBatch(chunk)...)
+		}
+		pushed = append(pushed, p.Flush()...)
+		assert.ElementsMatch(t, want, pushed, "processor, seed %d", seed)
+	}
+}
+
+func TestProcessorFlushUnpaired(t *testing.T) {
+	now := time.Now()
+
+	p := MustNewProcessor()
+
+	assert.Empty(t, p.PushBatch([]vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+	}))
+	assert.Empty(t, p.Flush())
+
+	var unpaired *UnpairedError
+	assert.ErrorAs(t, p.Err(), &unpaired)
+}
+
+func TestProcessorHDOPOnly(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.5},
+	}
+
+	p := MustNewProcessor()
+
+	out := append(p.PushBatch(input), p.Flush()...)
+
+	assert.NoError(t, p.Err())
+	assert.Equal(t, input, out)
+}
+
+func TestProcessorStats(t *testing.T) {
+	input := locgentest.GenerateTrack(locgentest.TrackOptions{
+		Seed:        7,
+		Fixes:       40,
+		Skew:        200 * time.Millisecond,
+		Duplicates:  5,
+		OriginFixes: 3,
+		Gaps:        2,
+	})
+	input = append(input, vss.Signal{TokenID: 1, Timestamp: input[len(input)-1].Timestamp.Add(time.Hour), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395})
+
+	_, want, _ := ProcessSignalsWithStats(input)
+
+	p := MustNewProcessor()
+	for chunk := range slices.Chunk(input, 7) {
+		p.PushBatch(chunk)
+	}
+	p.Flush()
+
+	assert.Equal(t, want, p.Stats())
+
+	p.Reset()
+	assert.Equal(t, Stats{}, p.Stats())
+}
+
+func TestProcessorLastLocations(t *testing.T) {
+	now := time.Now()
+
+	fix := func(token uint32, ts time.Time, lat, lon float64) []vss.Signal {
+		return []vss.Signal{
+			{TokenID: token, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: lat},
+			{TokenID: token, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: lon},
+		}
+	}
+
+	p := MustNewProcessor()
+	assert.Empty(t, p.LastLocations())
+
+	p.PushBatch(slices.Concat(
+		fix(3, now, 42.33432565967395, -83.06028627110183),
+		fix(4, now.Add(time.Second), 40.7128, -74.006),
+	))
+	p.PushBatch(slices.Concat(
+		fix(3, now.Add(time.Minute), 42.335848403478145, -83.07573579459459),
+		fix(4, now.Add(time.Minute+time.Second), 40.7131, -74.0072),
+	))
+	p.PushBatch(fix(3, now.Add(2*time.Minute), 42.336, -83.076))
+	p.Flush()
+
+	assert.Equal(t, map[uint32]vss.Signal{
+		3: {TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.336, Longitude: -83.076}},
+		4: {TokenID: 4, Timestamp: now.Add(time.Minute + time.Second), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 40.7131, Longitude: -74.0072}},
+	}, p.LastLocations())
+
+	p.Reset()
+	assert.Empty(t, p.LastLocations())
+}
+
+func TestProcessorReorderWindow(t *testing.T) {
+	now := time.Now()
+
+	lat := vss.Signal{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395}
+	speed := vss.Signal{TokenID: 3, Timestamp: now.Add(2 * time.Second), Name: vss.FieldSpeed, ValueNumber: 55}
+	// The longitude belongs with the latitude, but only arrives in the
+	// next batch.
+	lon := vss.Signal{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183}
+
+	p := MustNewProcessor(WithReorderWindow(5 * time.Second))
+
+	assert.Empty(t, p.PushBatch([]vss.Signal{lat, speed}))
+	out := slices.Concat(p.PushBatch([]vss.Signal{lon}), p.Flush())
+
+	assert.NoError(t, p.Err())
+	assert.ElementsMatch(t, []vss.Signal{
+		lat,
+		lon,
+		speed,
+		{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+	}, out)
+
+	// Without the window, the latitude has already given up.
+	p = MustNewProcessor()
+	p.PushBatch([]vss.Signal{lat, speed})
+
+	var unpaired *UnpairedError
+	assert.ErrorAs(t, p.Err(), &unpaired)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// QuarantinedSignal is an input signal that failed a check, along with
+// the Reason it was removed.
+type QuarantinedSignal struct {
+	Signal vss.Signal
+	Reason string
+}
+
+// ProcessSignalsWithQuarantine is like ProcessSignals, but also
+// returns the signals that were removed for failing a check, so that
+// they can be routed to a dead-letter queue. Duplicates and HDOPs
+// consumed under Config.ConsumeHDOP are not quarantined, since nothing
+// was wrong with them.
+func ProcessSignalsWithQuarantine(signals []vss.Signal, opts ...Option) ([]vss.Signal, []QuarantinedSignal, error) {
+	store := newStore(slices.Clone(signals), newConfig(opts))
+	kept := store.ProcessAll()
+
+	var quarantined []QuarantinedSignal
+	for i, sig := range store.dropped {
+		switch reason := store.droppedReasons[i]; reason {
+		case ReasonDuplicate, ReasonConsumed:
+		default:
+			quarantined = append(quarantined, QuarantinedSignal{Signal: sig, Reason: reason})
+		}
+	}
+
+	return kept, quarantined, store.Err()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessSignalsWithQuarantine(t *testing.T) {
+	now := time.Now()
+
+	lat := vss.Signal{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395}
+	lon := vss.Signal{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183}
+
+	withValue := func(sig vss.Signal, v float64) vss.Signal {
+		sig.ValueNumber = v
+		return sig
+	}
+	withSource := func(sig vss.Signal, source string) vss.Signal {
+		sig.Source = source
+		return sig
+	}
+
+	tests := []struct {
+		name   string
+		input  []vss.Signal
+		opts   []Option
+		reason string
+		count  int
+	}{
+		{"origin", []vss.Signal{withValue(lat, 0), withValue(lon, 0)}, nil, ReasonOrigin, 2},
+		{"unpaired", []vss.Signal{lat}, nil, ReasonUnpaired, 1},
+		{"out of range", []vss.Signal{withValue(lat, 95), lon}, nil, ReasonOutOfRange, 2},
+		{"future", []vss.Signal{{TokenID: 3, Timestamp: now.Add(2 * time.Hour), Name: vss.FieldSpeed, ValueNumber: 55}}, nil, ReasonFuture, 1},
+		{"hdop missing", []vss.Signal{lat, lon}, []Option{WithRequireHDOP()}, ReasonHDOPMissing, 2},
+		{"source mismatch", []vss.Signal{withSource(lat, "gps-a"), withSource(lon, "gps-b")}, []Option{WithSourceConsistency()}, ReasonSourceMismatch, 2},
+		{"frozen clock", []vss.Signal{lat, lon, withValue(lat, 42.3371)}, []Option{WithFrozenClockDetection(3, true)}, ReasonFrozenClock, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, quarantined, err := ProcessSignalsWithQuarantine(tt.input, tt.opts...)
+
+			assert.Error(t, err)
+			if assert.Len(t, quarantined, tt.count) {
+				for _, q := range quarantined {
+					assert.Equal(t, tt.reason, q.Reason)
+					assert.Contains(t, tt.input, q.Signal)
+				}
+			}
+		})
+	}
+}
+
+func TestQuarantineSkipsHarmlessDrops(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.2},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	kept, quarantined, err := ProcessSignalsWithQuarantine(input, WithConsumeHDOP())
+
+	assert.NoError(t, err)
+	assert.Empty(t, quarantined)
+	assert.Len(t, kept, 4)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// fieldLocationSatellites is the name of the companion signals emitted
+// under Config.SatellitesField.
+const fieldLocationSatellites = "currentLocationSatellites"
+
+// indexSatellites fills in sats, dropping the satellite counts that
+// are negative or not whole numbers.
+func (c *Store) indexSatellites() {
+	for i, sig := range c.signals {
+		if c.isDropped[i] || sig.Name != c.cfg.SatellitesField {
+			continue
+		}
+		// Written this way to catch NaN.
+		if n := sig.ValueNumber; !(n >= 0 && n == math.Trunc(n)) || math.IsInf(n, 0) {
+			c.drop(i, ReasonInvalidSatellites)
+			c.addErr(&InvalidSatellitesError{TokenID: sig.TokenID, Time: sig.Timestamp, Value: n})
+			continue
+		}
+		c.sats = append(c.sats, i)
+	}
+}
+
+// satelliteSignals returns, for each created location with a
+// satellite count within hdopWindow of it, a signal holding the count
+// nearest in time. Ties go to the earlier count.
+func (c *Store) satelliteSignals() []vss.Signal {
+	var out []vss.Signal
+	for _, loc := range c.created {
+		i := c.search(c.signals, c.sats, loc)
+
+		best := -1
+		var bestSpan int64
+		if i > 0 {
+			if sat := c.signals[c.sats[i-1]]; !c.separate(sat, loc) {
+				if span := c.span(sat, loc); span < c.hdopWindow() {
+					best, bestSpan = c.sats[i-1], span
+				}
+			}
+		}
+		if i < len(c.sats) {
+			if sat := c.signals[c.sats[i]]; !c.separate(loc, sat) {
+				if span := c.span(loc, sat); span < c.hdopWindow() && (best == -1 || span < bestSpan) {
+					best = c.sats[i]
+				}
+			}
+		}
+		if best == -1 {
+			continue
+		}
+
+		out = append(out, vss.Signal{
+			TokenID:      loc.TokenID,
+			Timestamp:    loc.Timestamp,
+			Name:         fieldLocationSatellites,
+			ValueNumber:  c.signals[best].ValueNumber,
+			Source:       loc.Source,
+			Producer:     loc.Producer,
+			CloudEventID: loc.CloudEventID,
+		})
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSatellites(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldDIMOAftermarketNSAT, ValueNumber: 9},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketNSAT, ValueNumber: 7.5},
+	}
+
+	actual, err := ProcessSignals(input, WithSatellites())
+
+	// The second count is bad, so the second location goes without.
+	expected := append(input[:5:5],
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}},
+		vss.Signal{TokenID: 3, Timestamp: now.Add(time.Minute), Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.335848403478145, Longitude: -83.07573579459459}},
+		vss.Signal{TokenID: 3, Timestamp: now, Name: fieldLocationSatellites, ValueNumber: 9},
+	)
+
+	var invalid *InvalidSatellitesError
+	if assert.ErrorAs(t, err, &invalid) {
+		assert.Equal(t, 7.5, invalid.Value)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
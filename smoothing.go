@@ -0,0 +1,38 @@
+package main
+
+import "github.com/DIMO-Network/model-garage/pkg/vss"
+
+// fieldCoordinatesSmoothed is the name of the signals emitted under
+// Config.SmoothingWindow.
+const fieldCoordinatesSmoothed = "currentLocationCoordinatesSmoothed"
+
+// smoothedLocations returns, for each location, a copy named
+// fieldCoordinatesSmoothed whose latitude and longitude are the means
+// of those of the last window locations for its TokenID, itself
+// included. Fewer are used at the start of a track. The HDOP is that
+// of the location itself. locs must be in time order.
+func smoothedLocations(locs []vss.Signal, window int) []vss.Signal {
+	recent := make(map[uint32][]vss.Location)
+
+	out := make([]vss.Signal, 0, len(locs))
+	for _, loc := range locs {
+		trail := append(recent[loc.TokenID], loc.ValueLocation)
+		if len(trail) > window {
+			trail = trail[len(trail)-window:]
+		}
+		recent[loc.TokenID] = trail
+
+		var lat, lon float64
+		for _, l := range trail {
+			lat += l.Latitude
+			lon += l.Longitude
+		}
+
+		sig := loc
+		sig.Name = fieldCoordinatesSmoothed
+		sig.ValueLocation.Latitude = lat / float64(len(trail))
+		sig.ValueLocation.Longitude = lon / float64(len(trail))
+		out = append(out, sig)
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmoothing(t *testing.T) {
+	now := time.Now()
+
+	// A track zig-zagging east.
+	lats := []float64{42.330, 42.332, 42.330, 42.332, 42.330}
+	lons := []float64{-83.050, -83.049, -83.048, -83.047, -83.046}
+
+	var input []vss.Signal
+	for i := range lats {
+		ts := now.Add(time.Duration(i) * time.Second)
+		input = append(input,
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLatitude, ValueNumber: lats[i]},
+			vss.Signal{TokenID: 3, Timestamp: ts, Name: vss.FieldCurrentLocationLongitude, ValueNumber: lons[i]},
+		)
+	}
+
+	actual, err := ProcessSignalsVerbose(input, WithSmoothing(3))
+
+	assert.NoError(t, err)
+	if !assert.Len(t, actual.Created, len(lats)) || !assert.Len(t, actual.Derived, len(lats)) {
+		return
+	}
+
+	for i, raw := range actual.Created {
+		assert.Equal(t, vss.Location{Latitude: lats[i], Longitude: lons[i]}, raw.ValueLocation)
+
+		smoothed := actual.Derived[i]
+		assert.Equal(t, fieldCoordinatesSmoothed, smoothed.Name)
+		assert.Equal(t, raw.Timestamp, smoothed.Timestamp)
+		assert.GreaterOrEqual(t, smoothed.ValueLocation.Latitude, 42.330)
+		assert.LessOrEqual(t, smoothed.ValueLocation.Latitude, 42.332)
+		assert.GreaterOrEqual(t, smoothed.ValueLocation.Longitude, -83.050)
+		assert.LessOrEqual(t, smoothed.ValueLocation.Longitude, -83.046)
+	}
+
+	// The first fix has nothing to average with, and the third sees
+	// the whole zig-zag.
+	assert.Equal(t, actual.Created[0].ValueLocation, actual.Derived[0].ValueLocation)
+	assert.InDelta(t, (42.330+42.332+42.330)/3, actual.Derived[2].ValueLocation.Latitude, 1e-9)
+	assert.InDelta(t, -83.049, actual.Derived[2].ValueLocation.Longitude, 1e-9)
+}
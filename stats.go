@@ -0,0 +1,80 @@
+package main
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// Stats summarizes the processing of a batch.
+type Stats struct {
+	// Input is the number of signals passed in.
+	Input int `json:"input"`
+	// Kept is the number of input signals that made it to the output.
+	Kept int `json:"kept"`
+	// Created is the number of location signals created.
+	Created int `json:"created"`
+	// Derived is the number of other signals computed from the
+	// created locations.
+	Derived int `json:"derived"`
+	// Dropped counts the input signals that were removed, by Reason.
+	Dropped map[string]int `json:"dropped,omitempty"`
+	// OrphanedHDOP is the number of HDOP signals that were not
+	// attached to any created location, and were passed through or
+	// discarded on their own. Quality monitors watch this to spot
+	// devices whose HDOP and coordinates have drifted apart.
+	OrphanedHDOP int `json:"orphanedHdop"`
+	// Errors is the number of errors returned, before any
+	// aggregation.
+	Errors int `json:"errors"`
+}
+
+// ProcessSignalsWithStats is like ProcessSignals, but also returns
+// statistics about the batch.
+func ProcessSignalsWithStats(signals []vss.Signal, opts ...Option) ([]vss.Signal, Stats, error) {
+	input := len(signals)
+	store := newStore(slices.Clone(signals), newConfig(opts))
+	out := store.ProcessAll()
+	return out, store.stats(input, len(out)), store.Err()
+}
+
+// ProcessSignalsClean is like ProcessSignals, but also reports whether
+// the batch was clean: no signal was dropped, duplicates and consumed
+// HDOPs included, and there was no error or warning. Callers that only
+// need to branch on this are spared picking apart the joined error.
+func ProcessSignalsClean(signals []vss.Signal, opts ...Option) (out []vss.Signal, clean bool, err error) {
+	store := newStore(slices.Clone(signals), newConfig(opts))
+	out = store.ProcessAll()
+	return out, len(store.dropped) == 0 && store.errCount == 0, store.Err()
+}
+
+// add adds the counts in o to s.
+func (s *Stats) add(o Stats) {
+	s.Input += o.Input
+	s.Kept += o.Kept
+	s.Created += o.Created
+	s.Derived += o.Derived
+	for reason, n := range o.Dropped {
+		if s.Dropped == nil {
+			s.Dropped = make(map[string]int)
+		}
+		s.Dropped[reason] += n
+	}
+	s.OrphanedHDOP += o.OrphanedHDOP
+	s.Errors += o.Errors
+}
+
+// stats tallies the outcome of the last batch, given the number of
+// input and output signals.
+func (c *Store) stats(input, output int) Stats {
+	return Stats{
+		Input:        input,
+		Kept:         output - len(c.created) - len(c.derived),
+		Created:      len(c.created),
+		Derived:      len(c.derived),
+		Dropped:      maps.Clone(c.droppedBy),
+		OrphanedHDOP: c.orphanedHDOPs,
+		Errors:       c.errCount,
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessSignalsWithStats(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+	}
+
+	_, stats, err := ProcessSignalsWithStats(input)
+
+	assert.Error(t, err)
+	assert.Equal(t, Stats{
+		Input:   6,
+		Kept:    3,
+		Created: 1,
+		Dropped: map[string]int{ReasonDuplicate: 1, ReasonOrigin: 2},
+		Errors:  1,
+	}, stats)
+}
+
+func TestProcessSignalsClean(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	t.Run("clean", func(t *testing.T) {
+		out, clean, err := ProcessSignalsClean(input)
+
+		assert.NoError(t, err)
+		assert.True(t, clean)
+		assert.Len(t, out, 4)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, clean, err := ProcessSignalsClean(append(input, input[2]))
+
+		assert.NoError(t, err)
+		assert.False(t, clean)
+	})
+
+	t.Run("unpaired", func(t *testing.T) {
+		_, clean, err := ProcessSignalsClean(input[1:])
+
+		assert.Error(t, err)
+		assert.False(t, clean)
+	})
+}
+
+func TestStatsOrphanedHDOP(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 1.2},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 2.5},
+	}
+
+	out, stats, err := ProcessSignalsWithStats(input)
+
+	assert.NoError(t, err)
+	assert.Contains(t, out, input[3])
+	assert.Equal(t, 1, stats.OrphanedHDOP)
+
+	// Without any coordinates, every HDOP is orphaned.
+	_, stats, err = ProcessSignalsWithStats(input[2:])
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.OrphanedHDOP)
+}
+
+func TestStatsJSONRoundTrip(t *testing.T) {
+	stats := Stats{Input: 6, Kept: 3, Created: 1, Dropped: map[string]int{ReasonOrigin: 2}, Errors: 1}
+
+	b, err := json.Marshal(stats)
+	assert.NoError(t, err)
+
+	var decoded Stats
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, stats, decoded)
+}
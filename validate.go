@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// ValidateSignal applies to a single signal the checks that
+// ProcessSignals makes without looking at any other signal, and
+// returns the same typed errors. These are:
+//
+//   - The timestamp may be no more than cfg.MaxFuture past cfg.Now.
+//   - A latitude must be within [-90, 90], and a longitude within
+//     [-180, 180], after wrapping if cfg.WrapLongitude is set. The
+//     OutOfRangeError has zero for the other coordinate.
+//   - A signal named cfg.CoordinatesField must not be at the origin,
+//     and its coordinates must be in range.
+//   - An HDOP must be positive.
+//
+// Checks that need a partner, like the origin check for separate
+// latitude and longitude signals, are not covered. Start from
+// DefaultConfig rather than a zero Config.
+func ValidateSignal(sig vss.Signal, cfg Config) error {
+	now := time.Now
+	if cfg.Now != nil {
+		now = cfg.Now
+	}
+
+	// Avoid returning a typed nil.
+	if err := validateSignal(sig, &cfg, instant(now())); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateSignal(sig vss.Signal, cfg *Config, now time.Time) categorizedError {
+	if err := checkFuture(sig, cfg.MaxFuture, now); err != nil {
+		return err
+	}
+
+	// Raw coordinates under cfg.Reproject can only be judged once
+	// both are known.
+	if cfg.Reproject != nil && (sig.Name == vss.FieldCurrentLocationLatitude || sig.Name == vss.FieldCurrentLocationLongitude) {
+		return nil
+	}
+
+	switch sig.Name {
+	case vss.FieldCurrentLocationLatitude:
+		// Written this way to catch NaN.
+		if lat := sig.ValueNumber; !(-90 <= lat && lat <= 90) {
+			return &OutOfRangeError{TokenID: sig.TokenID, Time: sig.Timestamp, Latitude: lat}
+		}
+	case vss.FieldCurrentLocationLongitude:
+		lon := sig.ValueNumber
+		if cfg.WrapLongitude {
+			lon = wrapLongitude(lon)
+		}
+		if !(-180 <= lon && lon <= 180) {
+			return &OutOfRangeError{TokenID: sig.TokenID, Time: sig.Timestamp, Longitude: lon}
+		}
+	case cfg.CoordinatesField:
+		return checkCoordinates(sig.TokenID, sig.Timestamp, sig.ValueLocation)
+	default:
+		if slices.Contains(cfg.HDOPFields, sig.Name) {
+			return checkHDOP(sig)
+		}
+	}
+
+	return nil
+}
+
+// checkFuture returns an error if sig is more than maxFuture past now.
+// A signal exactly maxFuture ahead is fine.
+func checkFuture(sig vss.Signal, maxFuture time.Duration, now time.Time) categorizedError {
+	if instant(sig.Timestamp).Sub(now) <= maxFuture {
+		return nil
+	}
+	return &FutureError{TokenID: sig.TokenID, Name: sig.Name, Time: sig.Timestamp}
+}
+
+// checkHDOP returns an error if the HDOP signal sig is not positive.
+// Real HDOP always is. A zero gets a NegativeHDOPError, and anything
+// else an InvalidHDOPError.
+func checkHDOP(sig vss.Signal) categorizedError {
+	if err := checkHDOPValue(sig); err != nil {
+		return err
+	}
+	if sig.ValueNumber > 0 {
+		return nil
+	}
+	return &NegativeHDOPError{TokenID: sig.TokenID, Time: sig.Timestamp, HDOP: sig.ValueNumber}
+}
+
+// checkHDOPValue returns an error if the HDOP signal sig is negative
+// or not finite. Such a value can't be anything but garbage, unlike a
+// zero, which some devices send to mean that they have no fix.
+func checkHDOPValue(sig vss.Signal) categorizedError {
+	// Written this way to catch NaN.
+	if 0 <= sig.ValueNumber && sig.ValueNumber <= math.MaxFloat64 {
+		return nil
+	}
+	return &InvalidHDOPError{TokenID: sig.TokenID, Name: sig.Name, Time: sig.Timestamp, HDOP: sig.ValueNumber}
+}
+
+// dropInvalidHDOPs drops the HDOP signals that fail checkHDOPValue,
+// whether or not they would have been attached to a location.
+func (c *Store) dropInvalidHDOPs() {
+	for i, sig := range c.signals {
+		if c.isDropped[i] || !c.isHDOP(sig.Name) {
+			continue
+		}
+		if err := checkHDOPValue(sig); err != nil {
+			c.drop(i, err.category())
+			c.addErr(err)
+		}
+	}
+}
+
+// dropMissingValues drops the latitudes and longitudes for which
+// cfg.HasValue returns false.
+func (c *Store) dropMissingValues() {
+	for i, sig := range c.signals {
+		if c.isDropped[i] || !isCoordinate(sig.Name) || c.hasValue(sig) {
+			continue
+		}
+		c.drop(i, ReasonMissingValue)
+		c.addErr(&MissingValueError{TokenID: sig.TokenID, Name: sig.Name, Time: sig.Timestamp})
+	}
+}
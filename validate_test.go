@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSignal(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	cfg := newConfig([]Option{WithClock(func() time.Time { return now })})
+
+	tests := []struct {
+		name string
+		sig  vss.Signal
+		want error
+	}{
+		{"valid latitude", vss.Signal{Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.3}, nil},
+		{"valid speed", vss.Signal{Timestamp: now, Name: vss.FieldSpeed, ValueNumber: -1}, nil},
+		{"future", vss.Signal{Timestamp: now.Add(2 * time.Hour), Name: vss.FieldSpeed}, &FutureError{}},
+		{"latitude out of range", vss.Signal{Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 95}, &OutOfRangeError{}},
+		{"latitude NaN", vss.Signal{Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: math.NaN()}, &OutOfRangeError{}},
+		{"longitude out of range", vss.Signal{Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: 280}, &OutOfRangeError{}},
+		{"coordinates at origin", vss.Signal{Timestamp: now, Name: fieldCoordinates}, &OriginError{}},
+		{"coordinates out of range", vss.Signal{Timestamp: now, Name: fieldCoordinates, ValueLocation: vss.Location{Latitude: 42.3, Longitude: math.Inf(1)}}, &OutOfRangeError{}},
+		{"zero HDOP", vss.Signal{Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: 0}, &NegativeHDOPError{}},
+		{"negative HDOP", vss.Signal{Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: -1}, &InvalidHDOPError{}},
+		{"HDOP NaN", vss.Signal{Timestamp: now, Name: vss.FieldDIMOAftermarketHDOP, ValueNumber: math.NaN()}, &InvalidHDOPError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSignal(tt.sig, cfg)
+			if tt.want == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateSignalWrapLongitude(t *testing.T) {
+	sig := vss.Signal{Timestamp: time.Now(), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 280}
+
+	assert.NoError(t, ValidateSignal(sig, newConfig([]Option{WithWrapLongitude()})))
+}
+
+func TestMissingValue(t *testing.T) {
+	now := time.Now()
+
+	// The decoder leaves ValueString empty when the payload had no
+	// value at all, which is the only way to tell from a real zero.
+	hasValue := func(sig vss.Signal) bool { return sig.ValueString != "" }
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395, ValueString: "42.33432565967395"},
+		{TokenID: 3, Timestamp: now.Add(100 * time.Millisecond), Name: vss.FieldCurrentLocationLatitude},
+		{TokenID: 3, Timestamp: now.Add(200 * time.Millisecond), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183, ValueString: "-83.06028627110183"},
+	}
+
+	actual, err := ProcessSignalsVerbose(slices.Clone(input), WithHasValue(hasValue))
+
+	var missing *MissingValueError
+	if assert.ErrorAs(t, err, &missing) {
+		assert.Equal(t, vss.FieldCurrentLocationLatitude, missing.Name)
+		assert.Equal(t, now.Add(100*time.Millisecond), missing.Time)
+	}
+	assert.Equal(t, []vss.Signal{input[1]}, actual.Dropped)
+	if assert.Len(t, actual.Signals, 3) {
+		created := actual.Signals[2]
+		assert.Equal(t, now, created.Timestamp)
+		assert.Equal(t, vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}, created.ValueLocation)
+	}
+}
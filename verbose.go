@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// Result is the detailed output of ProcessSignalsVerbose.
+type Result struct {
+	// Signals is the slice that ProcessSignals would have returned.
+	Signals []vss.Signal
+	// Created holds the location signals that were created. It is a
+	// subslice of Signals.
+	Created []vss.Signal
+	// Derived holds other signals computed from the created
+	// locations, such as travelled distance. It is a subslice of
+	// Signals.
+	Derived []vss.Signal
+	// Dropped holds the input signals that were removed from the
+	// output, with their original names.
+	Dropped []vss.Signal
+}
+
+// ProcessSignalsVerbose is like ProcessSignals, but also reports which
+// signals were created and which were dropped. Like ProcessSignals, it
+// works on a copy and does not modify the input slice.
+func ProcessSignalsVerbose(signals []vss.Signal, opts ...Option) (Result, error) {
+	store := newStore(slices.Clone(signals), newConfig(opts))
+	out, err := store.processSignals()
+
+	// The output ends with the created and then the derived signals.
+	derivedStart := len(out) - len(store.derived)
+	createdStart := derivedStart - len(store.created)
+
+	return Result{
+		Signals: out,
+		Created: out[createdStart:derivedStart],
+		Derived: out[derivedStart:],
+		Dropped: store.dropped,
+	}, err
+}
+
+// Reconciliation accounts for every input signal of a batch.
+type Reconciliation struct {
+	// InputCount is the number of signals passed in.
+	InputCount int
+	// KeptCount is the number of input signals that made it to the
+	// output.
+	KeptCount int
+	// CreatedCount is the number of location signals created.
+	CreatedCount int
+	// DerivedCount is the number of other signals computed from the
+	// created locations.
+	DerivedCount int
+	// DroppedCount is the number of input signals removed.
+	DroppedCount int
+}
+
+// Reconcile tallies the outcome of a call to ProcessSignalsVerbose.
+// Only the length of input is used. It returns an error if the counts
+// don't add up, which would indicate a bug in this package.
+func Reconcile(input []vss.Signal, res Result) (Reconciliation, error) {
+	r := Reconciliation{
+		InputCount:   len(input),
+		KeptCount:    len(res.Signals) - len(res.Created) - len(res.Derived),
+		CreatedCount: len(res.Created),
+		DerivedCount: len(res.Derived),
+		DroppedCount: len(res.Dropped),
+	}
+
+	if r.KeptCount+r.DroppedCount != r.InputCount {
+		return r, fmt.Errorf("kept %d and dropped %d signals, but there were %d in the input", r.KeptCount, r.DroppedCount, r.InputCount)
+	}
+
+	return r, nil
+}
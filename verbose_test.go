@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: 0},
+		{TokenID: 3, Timestamp: now.Add(2 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now.Add(3 * time.Minute), Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.335848403478145},
+		{TokenID: 3, Timestamp: now.Add(3 * time.Minute), Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.07573579459459},
+	}
+
+	res, err := ProcessSignalsVerbose(input)
+	assert.Error(t, err)
+
+	r, err := Reconcile(input, res)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Reconciliation{InputCount: 8, KeptCount: 5, CreatedCount: 2, DroppedCount: 3}, r)
+}
+
+func TestReconcileImbalance(t *testing.T) {
+	_, err := Reconcile(make([]vss.Signal, 3), Result{Signals: make([]vss.Signal, 1)})
+
+	assert.Error(t, err)
+}
+
+func TestProcessSignalsVerboseKeepsInput(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now.Add(time.Minute), Name: vss.FieldSpeed, ValueNumber: 55},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+	}
+	orig := slices.Clone(input)
+
+	res, err := ProcessSignalsVerbose(input)
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Created, 1)
+	assert.Equal(t, orig, input)
+}
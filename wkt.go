@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+)
+
+// WKTPoint is a location rendered as Well-Known Text, along with the
+// identifying fields of the signal it came from.
+type WKTPoint struct {
+	TokenID   uint32
+	Timestamp time.Time
+	WKT       string
+}
+
+// LocationToWKT renders loc as a Well-Known Text point. Per OGC, the
+// longitude comes first. Coordinates are written with as many digits
+// as are needed to round-trip the float64 exactly. HDOP is ignored.
+func LocationToWKT(loc vss.Location) string {
+	return "POINT(" + formatCoordinate(loc.Longitude) + " " + formatCoordinate(loc.Latitude) + ")"
+}
+
+// LocationsToWKT converts the location signals in the output of
+// ProcessSignals to WKT points. Other signals are skipped. Pass the
+// same options that were given to ProcessSignals so that the
+// coordinates field name agrees.
+func LocationsToWKT(signals []vss.Signal, opts ...Option) []WKTPoint {
+	cfg := newConfig(opts)
+
+	var out []WKTPoint
+	for _, sig := range signals {
+		if sig.Name == cfg.CoordinatesField {
+			out = append(out, WKTPoint{
+				TokenID:   sig.TokenID,
+				Timestamp: sig.Timestamp,
+				WKT:       LocationToWKT(sig.ValueLocation),
+			})
+		}
+	}
+	return out
+}
+
+// WKT renders b as a Well-Known Text polygon, running
+// counterclockwise from the southwest corner. A box that crosses the
+// antimeridian is written as is, and most readers will take it to
+// run the long way around.
+func (b *BoundingBox) WKT() string {
+	minLon, minLat := formatCoordinate(b.MinLon), formatCoordinate(b.MinLat)
+	maxLon, maxLat := formatCoordinate(b.MaxLon), formatCoordinate(b.MaxLat)
+	return "POLYGON((" + minLon + " " + minLat + ", " + maxLon + " " + minLat + ", " + maxLon + " " + maxLat + ", " + minLon + " " + maxLat + ", " + minLon + " " + minLat + "))"
+}
+
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
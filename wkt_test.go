@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DIMO-Network/model-garage/pkg/vss"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationToWKT(t *testing.T) {
+	assert.Equal(t, "POINT(-83.06028627110183 42.33432565967395)", LocationToWKT(vss.Location{Latitude: 42.33432565967395, Longitude: -83.06028627110183}))
+	assert.Equal(t, "POINT(151.2153 -33.8568)", LocationToWKT(vss.Location{Latitude: -33.8568, Longitude: 151.2153, HDOP: 2}))
+	assert.Equal(t, "POINT(0 51.4779)", LocationToWKT(vss.Location{Latitude: 51.4779}))
+}
+
+func TestLocationsToWKT(t *testing.T) {
+	now := time.Now()
+
+	input := []vss.Signal{
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLatitude, ValueNumber: 42.33432565967395},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldCurrentLocationLongitude, ValueNumber: -83.06028627110183},
+		{TokenID: 3, Timestamp: now, Name: vss.FieldSpeed, ValueNumber: 55},
+	}
+
+	out, err := ProcessSignals(input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []WKTPoint{
+		{TokenID: 3, Timestamp: now, WKT: "POINT(-83.06028627110183 42.33432565967395)"},
+	}, LocationsToWKT(out))
+}